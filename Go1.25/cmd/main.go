@@ -2,14 +2,20 @@ package main
 
 import (
 	"context"
+	"errors"
+	"net/http"
 	"os"
 	"os/signal"
 	"strings"
 	"syscall"
+	"time"
 
 	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/ArtemChadaev/SeeThisGame/internal/mailer"
+	"github.com/ArtemChadaev/SeeThisGame/internal/metrics"
 	"github.com/ArtemChadaev/SeeThisGame/internal/repository"
 	"github.com/ArtemChadaev/SeeThisGame/internal/service"
+	"github.com/ArtemChadaev/SeeThisGame/internal/tracing"
 	"github.com/ArtemChadaev/SeeThisGame/internal/transport/rest"
 	"github.com/joho/godotenv"
 	_ "github.com/lib/pq"
@@ -30,6 +36,16 @@ func main() {
 		logrus.Warn("no .env file found, using environment variables")
 	}
 
+	// Трассировка (OTLP или stdout): до подключения к БД и Redis, чтобы их спаны экспортировались
+	var tracingConfig tracing.Config
+	if err := viper.UnmarshalKey("tracing", &tracingConfig); err != nil {
+		logrus.Fatalf("failed to read tracing config: %s", err.Error())
+	}
+	shutdownTracing, err := tracing.Init(context.Background(), tracingConfig)
+	if err != nil {
+		logrus.Fatalf("failed to initialize tracing: %s", err.Error())
+	}
+
 	// 3. Подключение к БД (Postgres)
 	db, err := repository.NewPostgresDB(repository.PostgresConfig{
 		// Теперь viper будет проверять переменные окружения, если мы настроим его ниже
@@ -50,6 +66,7 @@ func main() {
 		logrus.Fatalf("Migrations failed: %s", err.Error())
 	}
 	logrus.Info("Migrations applied successfully!")
+	metrics.RegisterDB(db)
 
 	// 4. Подключение к Redis
 	redisClient, err := repository.NewRedisClient(repository.RedisConfig{
@@ -60,37 +77,122 @@ func main() {
 	if err != nil {
 		logrus.Fatalf("failed to initialize redis: %s", err.Error())
 	}
+	redisClient.AddHook(metrics.RedisHook{})
+
+	// 5. Загрузка ключей подписи JWT
+	var jwtConfig service.JWTConfig
+	if err := viper.UnmarshalKey("jwt", &jwtConfig); err != nil {
+		logrus.Fatalf("failed to read jwt config: %s", err.Error())
+	}
+	jwtKeys, err := service.NewJWTKeySet(jwtConfig)
+	if err != nil {
+		logrus.Fatalf("failed to load jwt keys: %s", err.Error())
+	}
+
+	// Relying party для входа по ключам доступа (WebAuthn)
+	var webAuthnConfig service.WebAuthnConfig
+	if err := viper.UnmarshalKey("webauthn", &webAuthnConfig); err != nil {
+		logrus.Fatalf("failed to read webauthn config: %s", err.Error())
+	}
+	webAuthn, err := service.NewWebAuthn(webAuthnConfig)
+	if err != nil {
+		logrus.Fatalf("failed to initialize webauthn: %s", err.Error())
+	}
+
+	// Провайдеры входа (Google, GitHub, любые OIDC/OAuth2 из конфига)
+	var oauthConfig service.OAuthConfig
+	if err := viper.UnmarshalKey("oauth", &oauthConfig); err != nil {
+		logrus.Fatalf("failed to read oauth config: %s", err.Error())
+	}
+	oauthProviders, err := service.NewOAuthProviders(oauthConfig)
+	if err != nil {
+		logrus.Fatalf("failed to initialize oauth providers: %s", err.Error())
+	}
+
+	// 6. Почта (SMTP в проде, лог/файлы локально)
+	mailSender, err := mailer.New(mailer.Config{
+		Driver:       viper.GetString("mail.driver"),
+		From:         viper.GetString("mail.from"),
+		Dir:          viper.GetString("mail.dir"),
+		SMTPHost:     viper.GetString("mail.smtp.host"),
+		SMTPPort:     viper.GetString("mail.smtp.port"),
+		SMTPUsername: viper.GetString("mail.smtp.username"),
+		SMTPPassword: os.Getenv("SMTP_PASSWORD"),
+	})
+	if err != nil {
+		logrus.Fatalf("failed to initialize mailer: %s", err.Error())
+	}
 
-	// 5. Инициализация слоев (Onion Architecture)
+	// 7. Инициализация слоев (Onion Architecture)
 	repos := repository.NewRepository(db)
-	services := service.NewService(repos, redisClient)
-	handlers := rest.NewHandler(services, redisClient)
+	services := service.NewService(repos, redisClient, jwtKeys, mailSender, webAuthn, oauthProviders)
+	// Cookie для режима сессии в браузере (refresh токен в HttpOnly cookie)
+	var sessionCookies rest.SessionCookieConfig
+	if err := viper.UnmarshalKey("sessionCookie", &sessionCookies); err != nil {
+		logrus.Fatalf("failed to read session cookie config: %s", err.Error())
+	}
+	// Политики ограничения частоты запросов по группам маршрутов
+	var rateLimits rest.RateLimitConfig
+	if err := viper.UnmarshalKey("rateLimit", &rateLimits); err != nil {
+		logrus.Fatalf("failed to read rate limit config: %s", err.Error())
+	}
+	handlers := rest.NewHandler(services, redisClient, sessionCookies, rateLimits)
 
-	// 6. Запуск HTTP сервера
-	srv := new(domain.Server)
+	// 8. Запуск HTTP сервера
+	srv := domain.NewServer(viper.GetString("port"), handlers.InitRoutes())
 
 	go func() {
-		if err := srv.Run(viper.GetString("port"), handlers.InitRoutes()); err != nil {
+		if err := srv.Run(); err != nil && !errors.Is(err, http.ErrServerClosed) {
 			logrus.Fatalf("error occurred while running http server: %s", err.Error())
 		}
 	}()
 
+	// Метрики Prometheus — отдельный порт, который не публикуется наружу
+	metricsPort := viper.GetString("metricsPort")
+	metricsSrv := domain.NewServer(metricsPort, rest.MetricsHandler())
+	if metricsPort != "" {
+		go func() {
+			if err := metricsSrv.Run(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				logrus.Fatalf("error occurred while running metrics server: %s", err.Error())
+			}
+		}()
+	}
+
 	logrus.Print("SeeThisGame app started")
 
-	// 7. Graceful Shutdown (Ожидание сигнала завершения)
+	// 9. Graceful Shutdown (Ожидание сигнала завершения)
 	quit := make(chan os.Signal, 1)
 	signal.Notify(quit, syscall.SIGTERM, syscall.SIGINT)
 	<-quit
 
 	logrus.Print("SeeThisGame app shutting down")
 
-	if err := srv.Shutdown(context.Background()); err != nil {
+	// /readyz сразу отвечает 503: балансировщик перестает слать новые запросы,
+	// пока сервер еще принимает соединения и дорабатывает текущие
+	services.HealthService.BeginShutdown()
+	time.Sleep(viper.GetDuration("health.shutdownDelay"))
+
+	// Текущие запросы дорабатывают не дольше shutdownTimeout, потом соединения закрываются
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), viper.GetDuration("health.shutdownTimeout"))
+	defer cancel()
+
+	if err := srv.Shutdown(shutdownCtx); err != nil {
 		logrus.Errorf("error occurred on server shutting down: %s", err.Error())
 	}
+	if metricsPort != "" {
+		if err := metricsSrv.Shutdown(shutdownCtx); err != nil {
+			logrus.Errorf("error occurred on metrics server shutting down: %s", err.Error())
+		}
+	}
 
 	if err := db.Close(); err != nil {
 		logrus.Errorf("error occurred on db connection close: %s", err.Error())
 	}
+
+	// Последним: спаны остановки сервера и закрытия БД тоже должны уйти в коллектор
+	if err := shutdownTracing(context.Background()); err != nil {
+		logrus.Errorf("error occurred on tracing shutdown: %s", err.Error())
+	}
 }
 
 func initConfig() error {
@@ -0,0 +1,28 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+type AccountRepository interface {
+	ScheduleAccountDeletion(ctx context.Context, userId int, at time.Time) error
+	CancelAccountDeletion(ctx context.Context, userId int) (bool, error)
+	DeleteScheduledAccounts(ctx context.Context) ([]int, error)
+	DeleteStaleGuests(ctx context.Context, inactiveFor time.Duration) ([]int, error)
+	ExportUserData(ctx context.Context, userId int) (map[string]json.RawMessage, error)
+}
+
+type AccountService interface {
+	ExportAccount(ctx context.Context, userId int) (AccountExport, error)
+	DeleteAccount(ctx context.Context, userId int, sessionId, password, code string, device DeviceInfo) (time.Time, error)
+	UpgradeGuest(ctx context.Context, userId int, email, password string) error
+	BeginGuestUpgrade(ctx context.Context, userId int, provider string) (authURL, state string, err error)
+}
+
+// AccountExport — архив с персональными данными пользователя
+type AccountExport struct {
+	FileName string
+	Data     []byte
+}
@@ -0,0 +1,56 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// APITokenPrefix отличает персональный токен от JWT в заголовке Authorization
+const APITokenPrefix = "stg_"
+
+// Области доступа персональных токенов. Какие маршруты открывает каждая область,
+// описано в rest (apiTokenRoutes); маршруты без области токенам недоступны.
+const (
+	ScopeSettingsRead  = "settings:read"
+	ScopeSettingsWrite = "settings:write"
+	// ScopeAdmin открывает /api/admin, но права все равно проверяются по ролям владельца
+	ScopeAdmin = "admin"
+)
+
+// APITokenScopes — все допустимые области
+var APITokenScopes = []string{ScopeSettingsRead, ScopeSettingsWrite, ScopeAdmin}
+
+type APITokenRepository interface {
+	CreateAPIToken(ctx context.Context, token APIToken) (int, error)
+	GetAPITokens(ctx context.Context, userId int) ([]APIToken, error)
+	GetAPITokenByHash(ctx context.Context, hash string) (APIToken, error)
+	TouchAPIToken(ctx context.Context, id int) error
+	DeleteAPIToken(ctx context.Context, userId, id int) (bool, error)
+	DeleteAllUserAPITokens(ctx context.Context, userId int) (int64, error)
+}
+
+type APITokenService interface {
+	CreateAPIToken(ctx context.Context, userId int, name string, scopes []string, ttl time.Duration) (NewAPIToken, error)
+	GetAPITokens(ctx context.Context, userId int) ([]APIToken, error)
+	DeleteAPIToken(ctx context.Context, userId, id int) error
+	ParseAPIToken(ctx context.Context, token string) (AccessTokenClaims, error)
+}
+
+// APIToken — персональный токен доступа (без самого токена)
+type APIToken struct {
+	ID         int        `json:"id"`
+	UserID     int        `json:"-"`
+	Name       string     `json:"name"`
+	TokenHash  string     `json:"-"`
+	Prefix     string     `json:"prefix"`
+	Scopes     []string   `json:"scopes"`
+	CreatedAt  time.Time  `json:"createdAt"`
+	ExpiresAt  *time.Time `json:"expiresAt"`
+	LastUsedAt *time.Time `json:"lastUsedAt"`
+}
+
+// NewAPIToken — только что созданный токен. Сам токен показывается один раз.
+type NewAPIToken struct {
+	APIToken
+	Token string `json:"token"`
+}
@@ -1,7 +1,9 @@
 package domain
 
 import (
+	"fmt"
 	"net/http"
+	"time"
 )
 
 // AppError — это наша основная структура для всех ошибок приложения.
@@ -47,11 +49,11 @@ var (
 		Message:    "authorization token is invalid",
 	}
 
-	// ErrTooManyRequestsByAccessToken Превышено количество запросов по токену
-	ErrTooManyRequestsByAccessToken = &AppError{
+	// ErrTooManyRequestsByUser Превышено количество запросов пользователя
+	ErrTooManyRequestsByUser = &AppError{
 		HTTPStatus: http.StatusTooManyRequests,
 		Code:       "too_many_requests",
-		Message:    "too many requests by access token",
+		Message:    "too many requests by user",
 	}
 	// ErrTooManyRequestsByIp Превышено количество запросов по ip
 	ErrTooManyRequestsByIp = &AppError{
@@ -60,6 +62,162 @@ var (
 		Message:    "too many requests by ip",
 	}
 
+	// ErrEmailNotVerified действие доступно только после подтверждения email
+	ErrEmailNotVerified = &AppError{
+		HTTPStatus: http.StatusForbidden,
+		Code:       "email_not_verified",
+		Message:    "email address is not verified",
+	}
+	// ErrEmailAlreadyVerified email уже подтвержден
+	ErrEmailAlreadyVerified = &AppError{
+		HTTPStatus: http.StatusConflict,
+		Code:       "email_already_verified",
+		Message:    "email address is already verified",
+	}
+	// ErrTooManyVerificationEmails письмо подтверждения запрашивают слишком часто
+	ErrTooManyVerificationEmails = &AppError{
+		HTTPStatus: http.StatusTooManyRequests,
+		Code:       "too_many_requests",
+		Message:    "verification email was sent recently, try again later",
+	}
+
+	// ErrInvalidMFACode неверный код TOTP или код восстановления
+	ErrInvalidMFACode = &AppError{
+		HTTPStatus: http.StatusUnauthorized,
+		Code:       "invalid_mfa_code",
+		Message:    "invalid two-factor authentication code",
+	}
+	// ErrMFANotEnrolled двухфакторная аутентификация не настроена
+	ErrMFANotEnrolled = &AppError{
+		HTTPStatus: http.StatusBadRequest,
+		Code:       "mfa_not_enrolled",
+		Message:    "two-factor authentication is not enrolled",
+	}
+	// ErrMFACodeRequired действие требует кода второго фактора, а он не передан
+	ErrMFACodeRequired = &AppError{
+		HTTPStatus: http.StatusUnauthorized,
+		Code:       "mfa_code_required",
+		Message:    "two-factor authentication code is required",
+	}
+	// ErrReauthenticationRequired действие доступно только сразу после входа
+	ErrReauthenticationRequired = &AppError{
+		HTTPStatus: http.StatusUnauthorized,
+		Code:       "reauthentication_required",
+		Message:    "sign in again to confirm this action",
+	}
+	// ErrMFAAlreadyEnabled двухфакторная аутентификация уже включена
+	ErrMFAAlreadyEnabled = &AppError{
+		HTTPStatus: http.StatusConflict,
+		Code:       "mfa_already_enabled",
+		Message:    "two-factor authentication is already enabled",
+	}
+
+	// ErrInvalidOAuthState state не совпал, устарел или уже использован (защита от login CSRF)
+	ErrInvalidOAuthState = &AppError{
+		HTTPStatus: http.StatusBadRequest,
+		Code:       "invalid_oauth_state",
+		Message:    "invalid or expired oauth state",
+	}
+
+	// ErrOAuthProviderNotFound провайдер не настроен
+	ErrOAuthProviderNotFound = &AppError{
+		HTTPStatus: http.StatusNotFound,
+		Code:       "oauth_provider_not_found",
+		Message:    "oauth provider is not configured",
+	}
+	// ErrForbidden у пользователя нет права на это действие
+	ErrForbidden = &AppError{
+		HTTPStatus: http.StatusForbidden,
+		Code:       "forbidden",
+		Message:    "insufficient permissions",
+	}
+	// ErrInsufficientScope персональному токену не выдана область для этого маршрута
+	ErrInsufficientScope = &AppError{
+		HTTPStatus: http.StatusForbidden,
+		Code:       "insufficient_scope",
+		Message:    "api token scopes do not allow this request",
+	}
+	// ErrAPITokenNotFound персонального токена нет или он чужой
+	ErrAPITokenNotFound = &AppError{
+		HTTPStatus: http.StatusNotFound,
+		Code:       "api_token_not_found",
+		Message:    "api token not found",
+	}
+	// ErrRoleNotFound роли нет или она не выдана пользователю
+	ErrRoleNotFound = &AppError{
+		HTTPStatus: http.StatusNotFound,
+		Code:       "role_not_found",
+		Message:    "role not found",
+	}
+	// ErrOwnRoles свои роли менять нельзя, даже администратору
+	ErrOwnRoles = &AppError{
+		HTTPStatus: http.StatusConflict,
+		Code:       "own_roles_change",
+		Message:    "you cannot change your own roles",
+	}
+	// ErrInvalidCSRFToken refresh токен пришел в cookie без совпадающего X-CSRF-Token
+	ErrInvalidCSRFToken = &AppError{
+		HTTPStatus: http.StatusForbidden,
+		Code:       "invalid_csrf_token",
+		Message:    "missing or invalid csrf token",
+	}
+	// ErrInvalidTelegramAuth подпись данных Telegram не сошлась, данные устарели или уже использованы
+	ErrInvalidTelegramAuth = &AppError{
+		HTTPStatus: http.StatusUnauthorized,
+		Code:       "invalid_telegram_auth",
+		Message:    "invalid or expired telegram authorization data",
+	}
+	// ErrIdentityEmailConflict email провайдера занят аккаунтом, а подтвердить владельца нельзя
+	ErrIdentityEmailConflict = &AppError{
+		HTTPStatus: http.StatusConflict,
+		Code:       "identity_email_conflict",
+		Message:    "an account with this email already exists, sign in and link the provider in settings",
+	}
+	// ErrIdentityAlreadyLinked аккаунт провайдера привязан к другому пользователю (или провайдер уже привязан)
+	ErrIdentityAlreadyLinked = &AppError{
+		HTTPStatus: http.StatusConflict,
+		Code:       "identity_already_linked",
+		Message:    "this provider account is already linked",
+	}
+	// ErrIdentityNotFound провайдер не привязан к аккаунту
+	ErrIdentityNotFound = &AppError{
+		HTTPStatus: http.StatusNotFound,
+		Code:       "identity_not_found",
+		Message:    "identity not found",
+	}
+	// ErrLastIdentity нельзя отвязать последний способ входа
+	ErrLastIdentity = &AppError{
+		HTTPStatus: http.StatusConflict,
+		Code:       "last_identity",
+		Message:    "cannot remove the last sign-in method",
+	}
+
+	// ErrInvalidPasskey ключ доступа не прошел проверку или церемония устарела
+	ErrInvalidPasskey = &AppError{
+		HTTPStatus: http.StatusUnauthorized,
+		Code:       "invalid_passkey",
+		Message:    "passkey verification failed",
+	}
+	// ErrPasskeyNotFound Ключ доступа не найден или принадлежит другому пользователю
+	ErrPasskeyNotFound = &AppError{
+		HTTPStatus: http.StatusNotFound,
+		Code:       "passkey_not_found",
+		Message:    "passkey not found",
+	}
+
+	// ErrSessionNotFound Сессия не найдена или принадлежит другому пользователю
+	ErrSessionNotFound = &AppError{
+		HTTPStatus: http.StatusNotFound,
+		Code:       "session_not_found",
+		Message:    "session not found",
+	}
+
+	// ErrNotGuest аккаунт уже полноценный, превращать его нечего
+	ErrNotGuest = &AppError{
+		HTTPStatus: http.StatusConflict,
+		Code:       "not_guest",
+		Message:    "account is not a guest account",
+	}
 	// ErrUserNotFound Пользователь не найден
 	ErrUserNotFound = &AppError{
 		HTTPStatus: http.StatusNotFound,
@@ -118,6 +276,27 @@ func NewInvalidRequestError(err error) *AppError {
 	}
 }
 
+// NewOAuthProviderError создает ошибку для сбоев на стороне провайдера входа
+// (discovery, обмен кода, невалидный ID токен).
+func NewOAuthProviderError(err error) *AppError {
+	return &AppError{
+		HTTPStatus: http.StatusBadGateway,
+		Code:       "oauth_provider_error",
+		Message:    "failed to sign in with the oauth provider",
+		Err:        err,
+	}
+}
+
+// NewAccountLockedError создает ошибку временной блокировки входа после перебора пароля.
+// Код отличается от too_many_requests, чтобы клиент мог показать, когда повторить попытку.
+func NewAccountLockedError(retryAfter time.Duration) *AppError {
+	return &AppError{
+		HTTPStatus: http.StatusTooManyRequests,
+		Code:       "account_locked",
+		Message:    fmt.Sprintf("too many failed sign-in attempts, try again in %d seconds", int(retryAfter.Seconds())),
+	}
+}
+
 // NewInternalServerError создает ошибку для всех непредвиденных сбоев.
 func NewInternalServerError(err error) *AppError {
 	return &AppError{
@@ -0,0 +1,42 @@
+package domain
+
+import "context"
+
+// Состояние проверки готовности
+const (
+	HealthStatusOK   = "ok"
+	HealthStatusFail = "fail"
+)
+
+type HealthRepository interface {
+	Ping(ctx context.Context) error
+	// MigrationVersion — примененная версия схемы и последняя версия среди встроенных миграций
+	MigrationVersion(ctx context.Context) (MigrationVersion, error)
+}
+
+type HealthService interface {
+	Readiness(ctx context.Context) Readiness
+	// BeginShutdown переводит сервис в состояние «не готов» перед остановкой сервера
+	BeginShutdown()
+}
+
+type MigrationVersion struct {
+	Current  uint
+	Expected uint
+	Dirty    bool
+}
+
+// Readiness — ответ /readyz: общий статус и статус каждой зависимости
+type Readiness struct {
+	Status string                      `json:"status"`
+	Checks map[string]DependencyStatus `json:"checks"`
+}
+
+type DependencyStatus struct {
+	Status    string `json:"status"`
+	LatencyMs int64  `json:"latencyMs,omitempty"`
+	Error     string `json:"error,omitempty"`
+	// Для миграций: примененная и ожидаемая версии схемы
+	Version  *uint `json:"version,omitempty"`
+	Expected *uint `json:"expected,omitempty"`
+}
@@ -0,0 +1,34 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+// IdentityPassword — вход по email и паролю. Хранится в users.password_hash,
+// но в списке способов входа показывается вместе с внешними провайдерами.
+const IdentityPassword = "password"
+
+type IdentityRepository interface {
+	GetIdentity(ctx context.Context, provider, providerUserId string) (Identity, error)
+	GetIdentities(ctx context.Context, userId int) ([]Identity, error)
+	CreateIdentity(ctx context.Context, identity Identity) error
+	TouchIdentity(ctx context.Context, id int) error
+	DeleteIdentity(ctx context.Context, userId int, provider string) (bool, error)
+}
+
+type IdentityService interface {
+	GetIdentities(ctx context.Context, userId int) ([]Identity, error)
+	UnlinkIdentity(ctx context.Context, userId int, provider string) error
+}
+
+// Identity — способ входа, привязанный к аккаунту (аккаунт Google, GitHub и т.д.)
+type Identity struct {
+	ID             int        `json:"-" db:"id"`
+	UserID         int        `json:"-" db:"user_id"`
+	Provider       string     `json:"provider" db:"provider"`
+	ProviderUserID string     `json:"-" db:"provider_user_id"`
+	Email          *string    `json:"email" db:"email"`
+	CreatedAt      time.Time  `json:"createdAt" db:"created_at"`
+	LastUsedAt     *time.Time `json:"lastUsedAt" db:"last_used_at"`
+}
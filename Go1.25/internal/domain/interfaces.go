@@ -1,27 +1,29 @@
 package domain
 
 import (
+	"context"
 	"time"
 )
 
 // --- REPOSITORY INTERFACES (Контракты для работы с данными) ---
 
 type UserSettingsRepository interface {
-	CreateUserSettings(settings UserSettings) error
-	GetUserSettings(userId int) (UserSettings, error)
-	UpdateUserSettings(settings UserSettings) error
-	UpdateUserCoin(userId int, coin int) error
-	BuyPaidSubscription(userId int, expiry time.Time) error
-	DeactivateExpiredSubscriptions() (int64, error)
+	CreateUserSettings(ctx context.Context, settings UserSettings) error
+	GetUserSettings(ctx context.Context, userId int) (UserSettings, error)
+	UpdateUserSettings(ctx context.Context, settings UserSettings) error
+	UpdateUserCoin(ctx context.Context, userId int, coin int) error
+	BuyPaidSubscription(ctx context.Context, userId int, expiry time.Time) error
+	DeactivateExpiredSubscriptions(ctx context.Context) (int64, error)
 }
 
 // --- SERVICE INTERFACES (Контракты бизнес-логики) ---
 
 type UserSettingsService interface {
-	CreateInitialUserSettings(userId int, name string) error
-	GetByUserID(userId int) (UserSettings, error)
-	UpdateInfo(userId int, name, icon string) error
-	ChangeCoins(userId, amount int) error
-	ActivateSubscription(userId, daysToAdd int, paymentToken string) error
-	GetGrantDailyReward(userId int) error
+	CreateInitialUserSettings(ctx context.Context, userId int, name, icon string) error
+	GetByUserID(ctx context.Context, userId int) (UserSettings, error)
+	UpdateInfo(ctx context.Context, userId int, name, icon string) error
+	ChangeCoins(ctx context.Context, userId, amount int) error
+	ActivateSubscription(ctx context.Context, userId, daysToAdd int, paymentToken string) error
+	GetGrantDailyReward(ctx context.Context, userId int) error
+	GetTier(ctx context.Context, userId int) (string, error)
 }
@@ -0,0 +1,21 @@
+package domain
+
+import "context"
+
+// MailMessage — простое текстовое письмо
+type MailMessage struct {
+	To      string
+	Subject string
+	Text    string
+}
+
+// Mailer отправляет письма. Реализации лежат в internal/mailer (SMTP и лог/файлы для локальной разработки).
+type Mailer interface {
+	Send(msg MailMessage) error
+}
+
+type EmailVerificationService interface {
+	SendVerificationEmail(ctx context.Context, userId int) error
+	ConfirmEmail(ctx context.Context, token string) error
+	IsEmailVerified(ctx context.Context, userId int) (bool, error)
+}
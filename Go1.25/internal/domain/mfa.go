@@ -0,0 +1,59 @@
+package domain
+
+import (
+	"context"
+	"time"
+)
+
+type MFARepository interface {
+	// TOTP
+	SaveTOTPSecret(ctx context.Context, userId int, secret string) error
+	GetTOTP(ctx context.Context, userId int) (TOTP, error)
+	EnableTOTP(ctx context.Context, userId int) error
+	UpdateTOTPLastStep(ctx context.Context, userId int, step int64) (bool, error)
+	DeleteTOTP(ctx context.Context, userId int) error
+
+	// Recovery codes
+	ReplaceRecoveryCodes(ctx context.Context, userId int, codeHashes []string) error
+	UseRecoveryCode(ctx context.Context, userId int, codeHash string) (bool, error)
+	DeleteRecoveryCodes(ctx context.Context, userId int) error
+}
+
+type MFAService interface {
+	EnrollTOTP(ctx context.Context, userId int) (TOTPEnrollment, error)
+	ConfirmTOTP(ctx context.Context, userId int, code string) ([]string, error)
+	DisableTOTP(ctx context.Context, userId int, code string) error
+	RegenerateRecoveryCodes(ctx context.Context, userId int, code string) ([]string, error)
+	CompleteSignIn(ctx context.Context, mfaToken, code string, device DeviceInfo) (ResponseTokens, error)
+}
+
+type TOTP struct {
+	UserID       int        `db:"user_id"`
+	Secret       string     `db:"secret"`
+	Enabled      bool       `db:"enabled"`
+	LastUsedStep int64      `db:"last_used_step"`
+	CreatedAt    time.Time  `db:"created_at"`
+	ConfirmedAt  *time.Time `db:"confirmed_at"`
+}
+
+// TOTPEnrollment — данные для добавления аккаунта в приложение-аутентификатор
+type TOTPEnrollment struct {
+	Secret string `json:"secret"`
+	URI    string `json:"otpauthUri"`
+}
+
+// MFAChallenge — ответ /auth/sign-in, если у пользователя включена двухфакторная аутентификация.
+// mfaToken нужно вместе с кодом отправить на /auth/sign-in/mfa.
+type MFAChallenge struct {
+	MFARequired bool   `json:"mfaRequired"`
+	MFAToken    string `json:"mfaToken"`
+	ExpiresIn   int    `json:"expiresIn"`
+}
+
+// SignInResult — результат входа: либо токены, либо MFA challenge.
+// Linked заполняется вместо них, если OAuth callback завершил привязку провайдера к аккаунту.
+type SignInResult struct {
+	Tokens    *ResponseTokens
+	Challenge *MFAChallenge
+	Linked    *Identity
+}
@@ -1,8 +1,12 @@
 package domain
 
+import "context"
+
 type OAuthService interface {
-	GetAuthURL(provider string) (string, error)
-	HandleCallback(provider, code string) (ResponseTokens, error)
+	GetAuthURL(ctx context.Context, provider string) (authURL, state string, err error)
+	GetLinkURL(ctx context.Context, userId int, provider string) (authURL, state string, err error)
+	HandleCallback(ctx context.Context, provider, code, state, browserState string, device DeviceInfo) (SignInResult, error)
+	HandleTelegram(ctx context.Context, data map[string]string, device DeviceInfo) (SignInResult, error)
 }
 
 // OAuthProvider represents supported OAuth providers
@@ -11,7 +15,9 @@ type OAuthProvider string
 const (
 	OAuthProviderGoogle OAuthProvider = "google"
 	OAuthProviderGitHub OAuthProvider = "github"
-	OAuthProviderLocal  OAuthProvider = "local"
+	// OAuthProviderTelegram — Telegram Login Widget, не OAuth, но хранится как такая же привязка
+	OAuthProviderTelegram OAuthProvider = "telegram"
+	OAuthProviderLocal    OAuthProvider = "local"
 )
 
 // OAuthConfig holds OAuth 2.0 configuration for a provider
@@ -31,6 +37,8 @@ type OAuthUserInfo struct {
 	Email    string
 	Name     string
 	Picture  string
+	// EmailVerified — провайдер подтверждает, что почта принадлежит пользователю
+	EmailVerified bool
 }
 
 // OAuthCallbackRequest represents the callback data from OAuth provider
@@ -0,0 +1,44 @@
+package domain
+
+import (
+	"context"
+	"encoding/json"
+	"time"
+)
+
+type PasskeyRepository interface {
+	CreatePasskey(ctx context.Context, passkey Passkey) (int, error)
+	GetPasskeys(ctx context.Context, userId int) ([]Passkey, error)
+	UpdatePasskeyCredential(ctx context.Context, id int, credential []byte) error
+	DeletePasskey(ctx context.Context, userId, id int) (bool, error)
+}
+
+type PasskeyService interface {
+	BeginPasskeyRegistration(ctx context.Context, userId int) (PasskeyCeremony, error)
+	FinishPasskeyRegistration(ctx context.Context, userId int, ceremonyId, name string, credential json.RawMessage) (Passkey, error)
+	BeginPasskeyLogin(ctx context.Context) (PasskeyCeremony, error)
+	FinishPasskeyLogin(ctx context.Context, ceremonyId string, credential json.RawMessage, device DeviceInfo) (ResponseTokens, error)
+	GetPasskeys(ctx context.Context, userId int) ([]Passkey, error)
+	DeletePasskey(ctx context.Context, userId, id int) error
+}
+
+// Passkey — ключ доступа WebAuthn, привязанный к пользователю.
+// Credential хранит запись библиотеки WebAuthn целиком (публичный ключ, счетчик подписей, флаги).
+type Passkey struct {
+	ID           int        `json:"id" db:"id"`
+	UserID       int        `json:"-" db:"user_id"`
+	CredentialID []byte     `json:"-" db:"credential_id"`
+	Credential   []byte     `json:"-" db:"credential"`
+	Name         *string    `json:"name" db:"name"`
+	CreatedAt    time.Time  `json:"createdAt" db:"created_at"`
+	LastUsedAt   *time.Time `json:"lastUsedAt" db:"last_used_at"`
+}
+
+// PasskeyCeremony — начало регистрации или входа по ключу доступа.
+// Options передаются в navigator.credentials.create/get, а ceremonyId
+// вместе с ответом браузера отправляется на соответствующий finish.
+type PasskeyCeremony struct {
+	CeremonyID string `json:"ceremonyId"`
+	Options    any    `json:"options"`
+	ExpiresIn  int    `json:"expiresIn"`
+}
@@ -0,0 +1,33 @@
+package domain
+
+import "context"
+
+// Права доступа. Новое право добавляется миграцией в таблицу permissions
+// и константой здесь, чтобы на него можно было сослаться в requirePermission.
+const (
+	PermissionRolesRead   = "roles:read"
+	PermissionRolesManage = "roles:manage"
+)
+
+type RoleRepository interface {
+	GetRoles(ctx context.Context) ([]Role, error)
+	GetUserRoles(ctx context.Context, userId int) ([]string, error)
+	AddUserRole(ctx context.Context, userId int, role string, grantedBy int) (bool, error)
+	DeleteUserRole(ctx context.Context, userId int, role string) (bool, error)
+}
+
+type RoleService interface {
+	GetRoles(ctx context.Context) ([]Role, error)
+	GetUserRoles(ctx context.Context, userId int) ([]string, error)
+	GrantRole(ctx context.Context, actorId, userId int, role string) error
+	RevokeRole(ctx context.Context, actorId, userId int, role string) error
+	HasPermission(ctx context.Context, roles []string, permission string) (bool, error)
+}
+
+// Role — набор прав, выдаваемый пользователю (admin, moderator и т.д.)
+type Role struct {
+	ID          int      `json:"-"`
+	Name        string   `json:"name"`
+	Description *string  `json:"description"`
+	Permissions []string `json:"permissions"`
+}
@@ -0,0 +1,25 @@
+package domain
+
+import "time"
+
+// Типы событий безопасности
+const (
+	// SecurityEventRefreshTokenReuse — предъявлен уже ротированный refresh токен, семейство отозвано
+	SecurityEventRefreshTokenReuse = "refresh_token_reuse"
+	// SecurityEventRoleGranted / SecurityEventRoleRevoked — изменение ролей пользователя
+	SecurityEventRoleGranted = "role_granted"
+	SecurityEventRoleRevoked = "role_revoked"
+	// SecurityEventSignInLocked — вход по email заблокирован после серии неверных паролей
+	SecurityEventSignInLocked = "sign_in_locked"
+	// SecurityEventMFALocked — проверка второго фактора заблокирована после серии неверных кодов
+	SecurityEventMFALocked = "mfa_locked"
+)
+
+// SecurityEvent — запись журнала security_events
+type SecurityEvent struct {
+	ID        int               `db:"id"`
+	UserID    int               `db:"user_id"`
+	Type      string            `db:"event_type"`
+	Details   map[string]string `db:"-"`
+	CreatedAt time.Time         `db:"created_at"`
+}
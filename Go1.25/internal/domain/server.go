@@ -10,15 +10,21 @@ type Server struct {
 	httpServer *http.Server
 }
 
-func (s *Server) Run(port string, handler http.Handler) error {
-	s.httpServer = &http.Server{
-		Addr:           ":" + port,
-		Handler:        handler,
-		MaxHeaderBytes: 1 << 20,
-		ReadTimeout:    10 * time.Second,
-		WriteTimeout:   10 * time.Second,
+// NewServer собирает http.Server заранее, чтобы Shutdown можно было вызвать
+// из другой горутины в любой момент, даже если Run еще не начал слушать порт
+func NewServer(port string, handler http.Handler) *Server {
+	return &Server{
+		httpServer: &http.Server{
+			Addr:           ":" + port,
+			Handler:        handler,
+			MaxHeaderBytes: 1 << 20,
+			ReadTimeout:    10 * time.Second,
+			WriteTimeout:   10 * time.Second,
+		},
 	}
+}
 
+func (s *Server) Run() error {
 	return s.httpServer.ListenAndServe()
 }
 
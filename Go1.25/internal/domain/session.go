@@ -0,0 +1,20 @@
+package domain
+
+import "time"
+
+// DeviceInfo — данные клиента, с которого выполняется вход или обновление токена
+type DeviceInfo struct {
+	UserAgent string
+	IP        string
+}
+
+// Session — активный вход пользователя (семейство refresh токенов) на одном устройстве
+type Session struct {
+	ID         string    `json:"id"`
+	NameDevice *string   `json:"nameDevice"`
+	DeviceInfo *string   `json:"deviceInfo"`
+	IP         *string   `json:"ip"`
+	CreatedAt  time.Time `json:"createdAt"`
+	LastUsedAt time.Time `json:"lastUsedAt"`
+	ExpiresAt  time.Time `json:"expiresAt"`
+}
@@ -1,48 +1,90 @@
 package domain
 
-import "time"
+import (
+	"context"
+	"time"
+)
 
 type AuthorizationRepository interface {
 	// User Management
-	CreateUser(user User) (int, error)
-	GetUser(username, password string) (int, error)
-	GetUserEmailFromId(id int) (string, error)
-	UpdateUserPassword(user User) error
+	CreateUser(ctx context.Context, user User) (int, error)
+	CreateGuestUser(ctx context.Context) (int, error)
+	UpgradeGuestUser(ctx context.Context, userId int, email, passwordHash string) (bool, error)
+	ClearGuest(ctx context.Context, userId int) error
+	GetUserEmailFromId(ctx context.Context, id int) (string, error)
+	GetUserById(ctx context.Context, id int) (User, error)
+	UpdateUserPassword(ctx context.Context, user User) error
+	SetEmailVerified(ctx context.Context, userId int) error
 
 	// Token Management
-	GetUserIdByRefreshToken(token string) (int, error)
-	CreateToken(token RefreshToken) error
-	GetRefreshToken(token string) (RefreshToken, error)
-	UpdateToken(oldToken string, newToken RefreshToken) error
-	DeleteRefreshToken(tokenId int) error
-	DeleteAllUserRefreshTokens(userId int) error
-	GetRefreshTokens(userId int) ([]RefreshToken, error)
+	GetUserIdByRefreshToken(ctx context.Context, token string) (int, error)
+	CreateToken(ctx context.Context, token RefreshToken) error
+	GetRefreshToken(ctx context.Context, token string) (RefreshToken, error)
+	RotateToken(ctx context.Context, oldTokenId int, newToken RefreshToken) error
+	GetRefreshTokenSuccessor(ctx context.Context, oldTokenId int, within time.Duration) (RefreshToken, error)
+	DeleteRotatedRefreshTokens(ctx context.Context, olderThan time.Duration) (int64, error)
+	UpdateRefreshTokenName(ctx context.Context, familyId, name string) error
+	DeleteRefreshToken(ctx context.Context, tokenId int) error
+	DeleteRefreshTokenFamily(ctx context.Context, familyId string) error
+	DeleteAllUserRefreshTokens(ctx context.Context, userId int) error
+	GetRefreshTokens(ctx context.Context, userId int) ([]RefreshToken, error)
 
 	// OAuth Management
-	CreateOAuthUser(user User) (int, error)
-	GetUserByOAuth(provider, oauthID string) (User, error)
-	GetUserByEmail(email string) (User, error)
+	CreateOAuthUser(ctx context.Context, user User, identity Identity) (int, error)
+	GetUserByEmail(ctx context.Context, email string) (User, error)
+
+	// Security Events
+	CreateSecurityEvent(ctx context.Context, event SecurityEvent) error
 }
 
 type AuthorizationService interface {
-	CreateUser(user User) (int, error)
-	GenerateTokens(email, password string) (ResponseTokens, error)
-	GetAccessToken(refreshToken string) (ResponseTokens, error)
-	ParseToken(accessToken string) (int, error)
-	UnAuthorize(refreshToken string) error
-	UnAuthorizeAll(email, password string) error
+	CreateUser(ctx context.Context, user User) (int, error)
+	CreateGuest(ctx context.Context, device DeviceInfo) (ResponseTokens, error)
+	GenerateTokens(ctx context.Context, email, password string, device DeviceInfo) (SignInResult, error)
+	GetAccessToken(ctx context.Context, refreshToken string, device DeviceInfo) (ResponseTokens, error)
+	ParseToken(ctx context.Context, accessToken string) (AccessTokenClaims, error)
+	GetJWKS() JSONWebKeySet
+	UnAuthorize(ctx context.Context, claims AccessTokenClaims) error
+	UnAuthorizeAll(ctx context.Context, userId int) error
+
+	// Sessions
+	GetSessions(ctx context.Context, userId int) ([]Session, error)
+	RenameSession(ctx context.Context, userId int, sessionId, name string) error
+	DeleteSession(ctx context.Context, userId int, sessionId string) error
+}
+
+type PasswordService interface {
+	RequestPasswordReset(ctx context.Context, email string) error
+	ResetPassword(ctx context.Context, token, newPassword string) error
+	ChangePassword(ctx context.Context, userId int, currentPassword, newPassword string, device DeviceInfo) (ResponseTokens, error)
 }
 
 type ResponseTokens struct {
 	AccessToken  string `json:"accessToken"`
 	RefreshToken string `json:"refreshToken"`
 }
+
+// AccessTokenClaims — проверенные данные access токена
+type AccessTokenClaims struct {
+	UserID int
+	// SessionID — семейство refresh токенов (id сессии в /api/sessions)
+	SessionID string
+	// TokenID — jti, нужен для отзыва конкретного токена
+	TokenID string
+	// Roles — роли на момент выдачи токена
+	Roles []string
+	// APITokenID не 0, если запрос пришел с персональным токеном; тогда доступ ограничен Scopes
+	APITokenID int
+	Scopes     []string
+	IssuedAt   time.Time
+	ExpiresAt  time.Time
+}
 type User struct {
-	ID            int     `json:"-" db:"id"`
-	Email         string  `json:"email" binding:"required"`
-	Password      string  `json:"password" binding:"required"`
-	OAuthProvider *string `json:"oauth_provider,omitempty" db:"oauth_provider"`
-	OAuthID       *string `json:"oauth_id,omitempty" db:"oauth_id"`
+	ID            int    `json:"-" db:"id"`
+	Email         string `json:"email" binding:"required,email"`
+	Password      string `json:"password" binding:"required" db:"password_hash"`
+	EmailVerified bool   `json:"-" db:"email_verified"`
+	IsGuest       bool   `json:"-" db:"is_guest"`
 }
 
 type RefreshToken struct {
@@ -54,8 +96,20 @@ type RefreshToken struct {
 	UpdatedAt  time.Time `db:"updated_at"`
 	NameDevice *string   `db:"name_device"`
 	DeviceInfo *string   `db:"device_info"`
+	// FamilyID объединяет все токены, полученные ротацией из одного входа
+	FamilyID   string     `db:"family_id"`
+	RotatedAt  *time.Time `db:"rotated_at"`
+	ReplacedBy *int       `db:"replaced_by"`
+	IPAddress  *string    `db:"ip_address"`
+	LastUsedAt time.Time  `db:"last_used_at"`
 }
 
+// Тарифы пользователя: от тарифа зависят лимиты запросов
+const (
+	TierFree = "free"
+	TierPaid = "paid"
+)
+
 type UserSettings struct {
 	UserID                 int        `json:"id" db:"user_id"`
 	Name                   string     `json:"name" db:"name"`
@@ -0,0 +1,46 @@
+// Package logger хранит логгер запроса в context.Context, чтобы записи сервисов
+// несли те же поля, что и access лог: request_id, user_id, route.
+package logger
+
+import (
+	"context"
+
+	"github.com/sirupsen/logrus"
+)
+
+type ctxKey struct{}
+
+// Поля, которые добавляются к логгеру запроса
+const (
+	FieldRequestID = "request_id"
+	FieldUserID    = "user_id"
+	FieldRoute     = "route"
+	FieldMethod    = "method"
+	FieldTraceID   = "trace_id"
+)
+
+// WithLogger возвращает контекст с логгером entry
+func WithLogger(ctx context.Context, entry *logrus.Entry) context.Context {
+	return context.WithValue(ctx, ctxKey{}, entry)
+}
+
+// WithFields добавляет поля к логгеру из контекста
+func WithFields(ctx context.Context, fields logrus.Fields) context.Context {
+	return WithLogger(ctx, FromContext(ctx).WithFields(fields))
+}
+
+// FromContext возвращает логгер запроса. Вне запроса (фоновые задачи) — общий логгер.
+func FromContext(ctx context.Context) *logrus.Entry {
+	if ctx != nil {
+		if entry, ok := ctx.Value(ctxKey{}).(*logrus.Entry); ok {
+			return entry
+		}
+	}
+	return logrus.NewEntry(logrus.StandardLogger())
+}
+
+// RequestID возвращает id запроса из контекста или пустую строку
+func RequestID(ctx context.Context) string {
+	id, _ := FromContext(ctx).Data[FieldRequestID].(string)
+	return id
+}
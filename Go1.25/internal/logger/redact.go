@@ -0,0 +1,59 @@
+package logger
+
+import (
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// Redacted подставляется вместо чувствительного значения
+const Redacted = "[REDACTED]"
+
+// sensitiveParams — параметры запроса, значения которых нельзя писать в лог:
+// токены, коды OAuth, подписи и пароли
+var sensitiveParams = map[string]bool{
+	"token":         true,
+	"access_token":  true,
+	"refresh_token": true,
+	"refreshtoken":  true,
+	"code":          true,
+	"state":         true,
+	"hash":          true,
+	"password":      true,
+	"email":         true,
+}
+
+var emailPattern = regexp.MustCompile(`([A-Za-z0-9._%+\-])[A-Za-z0-9._%+\-]*(@[A-Za-z0-9.\-]+\.[A-Za-z]{2,})`)
+
+// Redact маскирует адреса почты в произвольном тексте (например, в ошибке Postgres):
+// остаются первая буква и домен — john@example.com превращается в j***@example.com
+func Redact(text string) string {
+	return emailPattern.ReplaceAllString(text, "$1***$2")
+}
+
+// RedactQuery возвращает строку запроса, в которой скрыты значения чувствительных параметров
+func RedactQuery(rawQuery string) string {
+	if rawQuery == "" {
+		return ""
+	}
+
+	values, err := url.ParseQuery(rawQuery)
+	if err != nil {
+		return Redacted
+	}
+	for key, items := range values {
+		for i := range items {
+			if sensitiveParams[strings.ToLower(key)] {
+				items[i] = Redacted
+			} else {
+				items[i] = Redact(items[i])
+			}
+		}
+	}
+	// В логе строка нужна читаемой; управляющие символы экранирует JSON форматтер
+	encoded := values.Encode()
+	if decoded, err := url.QueryUnescape(encoded); err == nil {
+		return decoded
+	}
+	return encoded
+}
@@ -0,0 +1,37 @@
+package logger
+
+import "testing"
+
+func TestRedactQuery(t *testing.T) {
+	tests := []struct {
+		name  string
+		query string
+		want  string
+	}{
+		{"empty", "", ""},
+		{"no sensitive params", "page=2&sort=name", "page=2&sort=name"},
+		{"oauth callback", "code=4%2F0Adeu5BW&state=abc123", "code=[REDACTED]&state=[REDACTED]"},
+		{"token", "token=secret-value&lang=ru", "lang=ru&token=[REDACTED]"},
+		{"param name is case insensitive", "Refresh_Token=xyz", "Refresh_Token=[REDACTED]"},
+		{"all values of repeated param", "hash=a&hash=b", "hash=[REDACTED]&hash=[REDACTED]"},
+		{"email param", "email=john%40example.com", "email=[REDACTED]"},
+		{"email inside other param", "q=john@example.com", "q=j***@example.com"},
+		{"malformed escape", "token=%zz", Redacted},
+		{"semicolon separator", "token=abc;page=1", Redacted},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := RedactQuery(tt.query); got != tt.want {
+				t.Fatalf("RedactQuery(%q) = %q, want %q", tt.query, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedact(t *testing.T) {
+	got := Redact(`pq: duplicate key value violates unique constraint "users_email_key": john.doe@example.com`)
+	want := `pq: duplicate key value violates unique constraint "users_email_key": j***@example.com`
+	if got != want {
+		t.Fatalf("Redact = %q, want %q", got, want)
+	}
+}
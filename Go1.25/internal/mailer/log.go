@@ -0,0 +1,48 @@
+package mailer
+
+import (
+	"fmt"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/sirupsen/logrus"
+)
+
+// LogMailer ничего не отправляет: пишет письмо в лог и (если задана папка) сохраняет .eml файл.
+// Только для локальной разработки — в логе окажутся ссылки с токенами.
+type LogMailer struct {
+	dir  string
+	from *mail.Address
+}
+
+func NewLogMailer(dir string, from *mail.Address) (*LogMailer, error) {
+	if dir != "" {
+		if err := os.MkdirAll(dir, 0o750); err != nil {
+			return nil, err
+		}
+	}
+
+	return &LogMailer{dir: dir, from: from}, nil
+}
+
+func (m *LogMailer) Send(msg domain.MailMessage) error {
+	_, body, err := buildMessage(m.from, msg)
+	if err != nil {
+		return err
+	}
+
+	logrus.WithFields(logrus.Fields{
+		"to":      msg.To,
+		"subject": msg.Subject,
+	}).Infof("mail (log driver):\n%s", msg.Text)
+
+	if m.dir == "" {
+		return nil
+	}
+
+	name := fmt.Sprintf("%s.eml", time.Now().Format("20060102-150405.000000000"))
+	return os.WriteFile(filepath.Join(m.dir, name), body, 0o640)
+}
@@ -0,0 +1,80 @@
+package mailer
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"mime"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+	"time"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+)
+
+type Config struct {
+	// Driver — "smtp" или "log" (по умолчанию, для локальной разработки)
+	Driver string
+	From   string
+	// Dir — папка для .eml файлов у драйвера log. Пусто — письма только пишутся в лог.
+	Dir string
+
+	SMTPHost     string
+	SMTPPort     string
+	SMTPUsername string
+	SMTPPassword string
+}
+
+// New создает Mailer по конфигурации
+func New(cfg Config) (domain.Mailer, error) {
+	from, err := mail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mail.from: %w", err)
+	}
+
+	switch cfg.Driver {
+	case "smtp":
+		return NewSMTPMailer(cfg, from), nil
+	case "log", "":
+		return NewLogMailer(cfg.Dir, from)
+	default:
+		return nil, fmt.Errorf("unsupported mail driver %q", cfg.Driver)
+	}
+}
+
+// buildMessage собирает RFC 5322 письмо в UTF-8.
+// Адрес получателя проверяется через net/mail, поэтому внедрить лишние заголовки через него нельзя.
+func buildMessage(from *mail.Address, msg domain.MailMessage) (*mail.Address, []byte, error) {
+	to, err := mail.ParseAddress(msg.To)
+	if err != nil {
+		return nil, nil, fmt.Errorf("invalid recipient: %w", err)
+	}
+
+	messageId := make([]byte, 16)
+	if _, err := rand.Read(messageId); err != nil {
+		return nil, nil, err
+	}
+	domainPart := from.Address[strings.LastIndex(from.Address, "@")+1:]
+
+	var buf bytes.Buffer
+	fmt.Fprintf(&buf, "From: %s\r\n", from.String())
+	fmt.Fprintf(&buf, "To: %s\r\n", to.String())
+	fmt.Fprintf(&buf, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", msg.Subject))
+	fmt.Fprintf(&buf, "Date: %s\r\n", time.Now().Format(time.RFC1123Z))
+	fmt.Fprintf(&buf, "Message-ID: <%s@%s>\r\n", hex.EncodeToString(messageId), domainPart)
+	buf.WriteString("MIME-Version: 1.0\r\n")
+	buf.WriteString("Content-Type: text/plain; charset=UTF-8\r\n")
+	buf.WriteString("Content-Transfer-Encoding: quoted-printable\r\n\r\n")
+
+	qp := quotedprintable.NewWriter(&buf)
+	if _, err := qp.Write([]byte(msg.Text)); err != nil {
+		return nil, nil, err
+	}
+	if err := qp.Close(); err != nil {
+		return nil, nil, err
+	}
+
+	return to, buf.Bytes(), nil
+}
@@ -0,0 +1,38 @@
+package mailer
+
+import (
+	"net"
+	"net/mail"
+	"net/smtp"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+)
+
+// SMTPMailer отправляет письма через SMTP сервер (STARTTLS включается автоматически, если сервер его поддерживает).
+type SMTPMailer struct {
+	addr string
+	from *mail.Address
+	auth smtp.Auth
+}
+
+func NewSMTPMailer(cfg Config, from *mail.Address) *SMTPMailer {
+	var auth smtp.Auth
+	if cfg.SMTPUsername != "" {
+		auth = smtp.PlainAuth("", cfg.SMTPUsername, cfg.SMTPPassword, cfg.SMTPHost)
+	}
+
+	return &SMTPMailer{
+		addr: net.JoinHostPort(cfg.SMTPHost, cfg.SMTPPort),
+		from: from,
+		auth: auth,
+	}
+}
+
+func (m *SMTPMailer) Send(msg domain.MailMessage) error {
+	to, body, err := buildMessage(m.from, msg)
+	if err != nil {
+		return err
+	}
+
+	return smtp.SendMail(m.addr, m.auth, m.from.Address, []string{to.Address}, body)
+}
@@ -0,0 +1,114 @@
+// Package metrics описывает метрики Prometheus приложения. Все метрики регистрируются
+// в общем реестре и отдаются обработчиком /metrics на внутреннем порту metricsPort.
+package metrics
+
+import (
+	"time"
+
+	"github.com/jmoiron/sqlx"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+const namespace = "seethisgame"
+
+// Фоновые задачи (метка job)
+const (
+	JobSubscriptionChecker = "subscription_checker"
+	JobAccountDeletion     = "account_deletion"
+	JobRefreshTokenCleanup = "refresh_token_cleanup"
+)
+
+// Результат запуска фоновой задачи
+const (
+	JobResultSuccess = "success"
+	JobResultError   = "error"
+)
+
+// HTTP
+var (
+	HTTPRequests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Количество HTTP запросов по маршруту и статусу.",
+	}, []string{"method", "route", "status"})
+
+	HTTPRequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Время обработки HTTP запроса.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"method", "route", "status"})
+
+	RateLimitRejections = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "rate_limit_rejections_total",
+		Help:      "Запросы, отклоненные ограничением частоты, по политике (auth, api, ...).",
+	}, []string{"policy"})
+)
+
+// Redis
+var RedisCommandErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+	Namespace: namespace,
+	Subsystem: "redis",
+	Name:      "command_errors_total",
+	Help:      "Ошибки команд Redis (кроме отсутствующего ключа) по команде.",
+}, []string{"command"})
+
+// Бизнес события
+var DailyRewardClaims = promauto.NewCounter(prometheus.CounterOpts{
+	Namespace: namespace,
+	Name:      "daily_reward_claims_total",
+	Help:      "Выданные ежедневные награды.",
+})
+
+// Фоновые задачи
+var (
+	JobRuns = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "job",
+		Name:      "runs_total",
+		Help:      "Запуски фоновых задач по результату.",
+	}, []string{"job", "result"})
+
+	JobDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "job",
+		Name:      "duration_seconds",
+		Help:      "Время выполнения фоновой задачи.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"job"})
+
+	JobLastSuccess = promauto.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "job",
+		Name:      "last_success_timestamp_seconds",
+		Help:      "Время последнего успешного запуска фоновой задачи (unix).",
+	}, []string{"job"})
+
+	SubscriptionsDeactivated = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "subscriptions_deactivated_total",
+		Help:      "Подписки, деактивированные startSubscriptionChecker.",
+	})
+)
+
+// ObserveJob записывает запуск фоновой задачи job, начатый в start
+func ObserveJob(job string, start time.Time, err error) {
+	JobDuration.WithLabelValues(job).Observe(time.Since(start).Seconds())
+	if err != nil {
+		JobRuns.WithLabelValues(job, JobResultError).Inc()
+		return
+	}
+	JobRuns.WithLabelValues(job, JobResultSuccess).Inc()
+	JobLastSuccess.WithLabelValues(job).SetToCurrentTime()
+}
+
+// RegisterDB добавляет статистику пула соединений Postgres (sql.DBStats)
+func RegisterDB(db *sqlx.DB) {
+	prometheus.MustRegister(collectors.NewDBStatsCollector(db.DB, "postgres"))
+}
@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
+	"github.com/jmoiron/sqlx"
+)
+
+// personalDataSections — что попадает в выгрузку данных пользователя: раздел → запрос по $1 = user_id.
+// Новая таблица с данными пользователя (история монет, игровые данные) добавляется сюда одной строкой.
+// Секреты (хеш пароля, токены, секрет TOTP, ключи WebAuthn) не выгружаются.
+var personalDataSections = []struct {
+	name  string
+	query string
+}{
+	{"account", `SELECT id, email, email_verified, email_verified_at, is_guest, updated_at, deletion_scheduled_at
+	             FROM users WHERE id=$1`},
+	{"settings", `SELECT * FROM user_settings WHERE user_id=$1`},
+	{"sessions", `SELECT family_id, name_device, device_info, ip_address, created_at, last_used_at, expires_at
+	              FROM user_refresh_tokens WHERE user_id=$1 AND rotated_at IS NULL ORDER BY created_at`},
+	{"identities", `SELECT provider, provider_user_id, email, created_at, last_used_at
+	                FROM user_identities WHERE user_id=$1 ORDER BY created_at`},
+	{"passkeys", `SELECT name, created_at, last_used_at FROM user_passkeys WHERE user_id=$1 ORDER BY created_at`},
+	{"api_tokens", `SELECT name, prefix, scopes, created_at, expires_at, last_used_at
+	                FROM api_tokens WHERE user_id=$1 ORDER BY created_at`},
+	{"mfa", `SELECT enabled, created_at, confirmed_at FROM user_totp WHERE user_id=$1`},
+	{"roles", `SELECT r.name, ur.granted_at FROM user_roles ur JOIN roles r ON r.id = ur.role_id
+	           WHERE ur.user_id=$1 ORDER BY ur.granted_at`},
+	{"security_events", `SELECT event_type, details, created_at FROM security_events
+	                     WHERE user_id=$1 ORDER BY created_at`},
+}
+
+type AccountRepository struct {
+	db *sqlx.DB
+}
+
+func NewAccountPostgres(db *sqlx.DB) *AccountRepository {
+	return &AccountRepository{db: db}
+}
+
+func (r *AccountRepository) ScheduleAccountDeletion(ctx context.Context, userId int, at time.Time) error {
+	query := "UPDATE users SET deletion_scheduled_at=$1 WHERE id=$2"
+	_, err := r.db.ExecContext(ctx, query, at, userId)
+	return err
+}
+
+// CancelAccountDeletion снимает запланированное удаление. false — удаление не было запланировано.
+func (r *AccountRepository) CancelAccountDeletion(ctx context.Context, userId int) (bool, error) {
+	query := "UPDATE users SET deletion_scheduled_at=NULL WHERE id=$1 AND deletion_scheduled_at IS NOT NULL"
+	result, err := r.db.ExecContext(ctx, query, userId)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected == 1, err
+}
+
+// DeleteScheduledAccounts окончательно удаляет аккаунты, у которых истек срок, и возвращает их id.
+func (r *AccountRepository) DeleteScheduledAccounts(ctx context.Context) ([]int, error) {
+	var ids []int
+	query := "DELETE FROM users WHERE deletion_scheduled_at <= NOW() RETURNING id"
+	err := r.db.SelectContext(ctx, &ids, query)
+	return ids, err
+}
+
+// DeleteStaleGuests удаляет гостевые аккаунты, которыми не пользовались inactiveFor:
+// ни одна сессия не обновлялась и сам аккаунт создан раньше этого срока.
+func (r *AccountRepository) DeleteStaleGuests(ctx context.Context, inactiveFor time.Duration) ([]int, error) {
+	var ids []int
+	query := `DELETE FROM users u
+	          WHERE u.is_guest
+	            AND u.updated_at < NOW() - $1 * INTERVAL '1 second'
+	            AND NOT EXISTS (SELECT 1 FROM user_refresh_tokens t
+	                            WHERE t.user_id = u.id AND t.last_used_at >= NOW() - $1 * INTERVAL '1 second')
+	          RETURNING u.id`
+	err := r.db.SelectContext(ctx, &ids, query, inactiveFor.Seconds())
+	return ids, err
+}
+
+// ExportUserData собирает все разделы personalDataSections в одном снимке (REPEATABLE READ),
+// чтобы выгрузка была согласованной. Каждый раздел — JSON массив строк.
+func (r *AccountRepository) ExportUserData(ctx context.Context, userId int) (map[string]json.RawMessage, error) {
+	tx, err := r.db.BeginTxx(ctx, &sql.TxOptions{Isolation: sql.LevelRepeatableRead, ReadOnly: true})
+	if err != nil {
+		return nil, err
+	}
+	defer tx.Rollback()
+
+	data := make(map[string]json.RawMessage, len(personalDataSections))
+	for _, section := range personalDataSections {
+		var rows []byte
+		query := "SELECT COALESCE(json_agg(t), '[]') FROM (" + section.query + ") t"
+		if err := tx.GetContext(ctx, &rows, query, userId); err != nil {
+			return nil, err
+		}
+		data[section.name] = rows
+	}
+
+	return data, tx.Commit()
+}
@@ -0,0 +1,112 @@
+package repository
+
+import (
+	"context"
+	"time"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+// apiTokenTouchInterval — last_used_at обновляется не чаще этого, чтобы не писать в базу на каждый запрос
+const apiTokenTouchInterval = time.Minute
+
+type apiTokenRow struct {
+	ID         int            `db:"id"`
+	UserID     int            `db:"user_id"`
+	Name       string         `db:"name"`
+	TokenHash  string         `db:"token_hash"`
+	Prefix     string         `db:"prefix"`
+	Scopes     pq.StringArray `db:"scopes"`
+	CreatedAt  time.Time      `db:"created_at"`
+	ExpiresAt  *time.Time     `db:"expires_at"`
+	LastUsedAt *time.Time     `db:"last_used_at"`
+}
+
+func (row apiTokenRow) toDomain() domain.APIToken {
+	return domain.APIToken{
+		ID:         row.ID,
+		UserID:     row.UserID,
+		Name:       row.Name,
+		TokenHash:  row.TokenHash,
+		Prefix:     row.Prefix,
+		Scopes:     row.Scopes,
+		CreatedAt:  row.CreatedAt,
+		ExpiresAt:  row.ExpiresAt,
+		LastUsedAt: row.LastUsedAt,
+	}
+}
+
+type APITokenRepository struct {
+	db *sqlx.DB
+}
+
+func NewAPITokenPostgres(db *sqlx.DB) *APITokenRepository {
+	return &APITokenRepository{db: db}
+}
+
+func (r *APITokenRepository) CreateAPIToken(ctx context.Context, token domain.APIToken) (int, error) {
+	var id int
+	query := `INSERT INTO api_tokens (user_id, name, token_hash, prefix, scopes, expires_at)
+	          VALUES ($1, $2, $3, $4, $5, $6) RETURNING id`
+	row := r.db.QueryRowContext(ctx, query, token.UserID, token.Name, token.TokenHash, token.Prefix, pq.StringArray(token.Scopes), token.ExpiresAt)
+	if err := row.Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *APITokenRepository) GetAPITokens(ctx context.Context, userId int) ([]domain.APIToken, error) {
+	var rows []apiTokenRow
+	query := "SELECT * FROM api_tokens WHERE user_id=$1 ORDER BY created_at"
+	if err := r.db.SelectContext(ctx, &rows, query, userId); err != nil {
+		return nil, err
+	}
+
+	tokens := make([]domain.APIToken, 0, len(rows))
+	for _, row := range rows {
+		tokens = append(tokens, row.toDomain())
+	}
+	return tokens, nil
+}
+
+// GetAPITokenByHash ищет токен. Токены аккаунта, ожидающего удаления, не действуют.
+func (r *APITokenRepository) GetAPITokenByHash(ctx context.Context, hash string) (domain.APIToken, error) {
+	var row apiTokenRow
+	query := `SELECT t.* FROM api_tokens t
+	          JOIN users u ON u.id = t.user_id
+	          WHERE t.token_hash=$1 AND u.deletion_scheduled_at IS NULL`
+	if err := r.db.GetContext(ctx, &row, query, hash); err != nil {
+		return domain.APIToken{}, err
+	}
+	return row.toDomain(), nil
+}
+
+func (r *APITokenRepository) TouchAPIToken(ctx context.Context, id int) error {
+	query := `UPDATE api_tokens SET last_used_at=NOW()
+	          WHERE id=$1 AND (last_used_at IS NULL OR last_used_at < NOW() - $2 * INTERVAL '1 second')`
+	_, err := r.db.ExecContext(ctx, query, id, apiTokenTouchInterval.Seconds())
+	return err
+}
+
+// DeleteAPIToken отзывает токен пользователя. false — токена нет или он чужой.
+func (r *APITokenRepository) DeleteAPIToken(ctx context.Context, userId, id int) (bool, error) {
+	query := "DELETE FROM api_tokens WHERE id=$1 AND user_id=$2"
+	result, err := r.db.ExecContext(ctx, query, id, userId)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected == 1, err
+}
+
+// DeleteAllUserAPITokens отзывает все токены пользователя (сброс пароля, удаление аккаунта)
+func (r *APITokenRepository) DeleteAllUserAPITokens(ctx context.Context, userId int) (int64, error) {
+	query := "DELETE FROM api_tokens WHERE user_id=$1"
+	result, err := r.db.ExecContext(ctx, query, userId)
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
+}
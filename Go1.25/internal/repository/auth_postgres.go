@@ -1,6 +1,11 @@
 package repository
 
 import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"time"
+
 	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
 	"github.com/jmoiron/sqlx"
 )
@@ -13,112 +18,227 @@ func NewAuthPostgres(db *sqlx.DB) *AuthRepository {
 	return &AuthRepository{db: db}
 }
 
-func (r *AuthRepository) CreateUser(user domain.User) (int, error) {
+func (r *AuthRepository) CreateUser(ctx context.Context, user domain.User) (int, error) {
 	var id int
 	query := "INSERT INTO users (email, password_hash) VALUES ($1, $2) RETURNING id"
-	row := r.db.QueryRow(query, user.Email, user.Password)
+	row := r.db.QueryRowContext(ctx, query, user.Email, user.Password)
 	if err := row.Scan(&id); err != nil {
 		return 0, err
 	}
 	return id, nil
 }
 
-func (r *AuthRepository) GetUser(email, password string) (int, error) {
+// CreateGuestUser создает гостевой аккаунт без email и пароля
+func (r *AuthRepository) CreateGuestUser(ctx context.Context) (int, error) {
 	var id int
-	query := "SELECT id FROM users WHERE email=$1 and password_hash=$2"
-	err := r.db.Get(&id, query, email, password)
+	query := "INSERT INTO users (is_guest) VALUES (TRUE) RETURNING id"
+	if err := r.db.QueryRowContext(ctx, query).Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
 
-	return id, err
+// UpgradeGuestUser задает гостю email и пароль. false — аккаунт уже не гостевой.
+func (r *AuthRepository) UpgradeGuestUser(ctx context.Context, userId int, email, passwordHash string) (bool, error) {
+	query := "UPDATE users SET email=$1, password_hash=$2, is_guest=FALSE WHERE id=$3 AND is_guest"
+	result, err := r.db.ExecContext(ctx, query, email, passwordHash, userId)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected == 1, err
 }
 
-func (r *AuthRepository) GetUserEmailFromId(id int) (string, error) {
+// ClearGuest делает аккаунт обычным (гость привязал провайдера входа)
+func (r *AuthRepository) ClearGuest(ctx context.Context, userId int) error {
+	query := "UPDATE users SET is_guest=FALSE WHERE id=$1 AND is_guest"
+	_, err := r.db.ExecContext(ctx, query, userId)
+	return err
+}
+
+func (r *AuthRepository) GetUserEmailFromId(ctx context.Context, id int) (string, error) {
 	var userEmail string
-	query := "SELECT email FROM users WHERE id=$1"
-	err := r.db.Get(&userEmail, query, id)
+	query := "SELECT COALESCE(email, '') FROM users WHERE id=$1"
+	err := r.db.GetContext(ctx, &userEmail, query, id)
 	if err != nil {
 		return "", err
 	}
 	return userEmail, err
 }
 
-func (r *AuthRepository) UpdateUserPassword(user domain.User) error {
+func (r *AuthRepository) GetUserById(ctx context.Context, id int) (domain.User, error) {
+	var user domain.User
+	query := `SELECT id, COALESCE(email, '') AS email, COALESCE(password_hash, '') AS password_hash, email_verified, is_guest
+	          FROM users WHERE id=$1`
+	err := r.db.GetContext(ctx, &user, query, id)
+	return user, err
+}
+
+func (r *AuthRepository) SetEmailVerified(ctx context.Context, userId int) error {
+	query := "UPDATE users SET email_verified=true, email_verified_at=NOW() WHERE id=$1 AND email_verified=false"
+	_, err := r.db.ExecContext(ctx, query, userId)
+	return err
+}
+
+func (r *AuthRepository) UpdateUserPassword(ctx context.Context, user domain.User) error {
 	query := "UPDATE users SET password_hash=$1 WHERE id=$2"
-	_, err := r.db.Exec(query, user.Password, user.ID)
+	_, err := r.db.ExecContext(ctx, query, user.Password, user.ID)
 	return err
 }
 
-func (r *AuthRepository) GetUserIdByRefreshToken(refreshToken string) (int, error) {
+func (r *AuthRepository) GetUserIdByRefreshToken(ctx context.Context, refreshToken string) (int, error) {
 	var userId int
 	query := "SELECT user_id FROM user_refresh_tokens WHERE token=$1"
-	err := r.db.Get(&userId, query, refreshToken)
+	err := r.db.GetContext(ctx, &userId, query, refreshToken)
 	if err != nil {
 		return 0, err
 	}
 	return userId, err
 }
 
-func (r *AuthRepository) CreateToken(refreshToken domain.RefreshToken) error {
-	query := "INSERT INTO user_refresh_tokens (user_id, token, expires_at, name_device, device_info) VALUES ($1, $2, $3, $4, $5)"
-	_, err := r.db.Exec(query, refreshToken.UserID, refreshToken.Token, refreshToken.ExpiresAt, refreshToken.NameDevice, refreshToken.DeviceInfo)
+func (r *AuthRepository) CreateToken(ctx context.Context, refreshToken domain.RefreshToken) error {
+	query := "INSERT INTO user_refresh_tokens (user_id, token, expires_at, name_device, device_info, family_id, ip_address) VALUES ($1, $2, $3, $4, $5, $6, $7)"
+	_, err := r.db.ExecContext(ctx, query, refreshToken.UserID, refreshToken.Token, refreshToken.ExpiresAt, refreshToken.NameDevice, refreshToken.DeviceInfo, refreshToken.FamilyID, refreshToken.IPAddress)
 	return err
 }
 
-func (r *AuthRepository) GetRefreshToken(refreshToken string) (domain.RefreshToken, error) {
+func (r *AuthRepository) GetRefreshToken(ctx context.Context, refreshToken string) (domain.RefreshToken, error) {
 	var refresh domain.RefreshToken
 	query := "SELECT * FROM user_refresh_tokens WHERE token=$1"
-	err := r.db.Get(&refresh, query, refreshToken)
+	err := r.db.GetContext(ctx, &refresh, query, refreshToken)
 	return refresh, err
 }
 
-func (r *AuthRepository) UpdateToken(oldRefreshToken string, refreshToken domain.RefreshToken) error {
-	query := "UPDATE user_refresh_tokens SET token=$1, expires_at=$2, name_device=$3, device_info=$4 WHERE token=$5"
-	_, err := r.db.Exec(query, refreshToken.Token, refreshToken.ExpiresAt, refreshToken.NameDevice, refreshToken.DeviceInfo, oldRefreshToken)
-	return err
+// RotateToken помечает старый токен ротированным и создает новый в том же семействе.
+// Если старый токен уже был ротирован (гонка двух запросов), возвращает sql.ErrNoRows.
+func (r *AuthRepository) RotateToken(ctx context.Context, oldTokenId int, refreshToken domain.RefreshToken) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	result, err := tx.ExecContext(ctx, "UPDATE user_refresh_tokens SET rotated_at=NOW() WHERE id=$1 AND rotated_at IS NULL", oldTokenId)
+	if err != nil {
+		return err
+	}
+	rowsAffected, err := result.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if rowsAffected == 0 {
+		return sql.ErrNoRows
+	}
+
+	// created_at переносится со старого токена, чтобы хранить время начала сессии
+	query := `INSERT INTO user_refresh_tokens (user_id, token, expires_at, name_device, device_info, family_id, ip_address, created_at)
+	          SELECT $1, $2, $3, $4, $5, $6, $7, created_at FROM user_refresh_tokens WHERE id=$8
+	          RETURNING id`
+	var newTokenId int
+	if err := tx.QueryRowContext(ctx, query, refreshToken.UserID, refreshToken.Token, refreshToken.ExpiresAt, refreshToken.NameDevice, refreshToken.DeviceInfo, refreshToken.FamilyID, refreshToken.IPAddress, oldTokenId).Scan(&newTokenId); err != nil {
+		return err
+	}
+
+	if _, err := tx.ExecContext(ctx, "UPDATE user_refresh_tokens SET replaced_by=$1 WHERE id=$2", newTokenId, oldTokenId); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+// GetRefreshTokenSuccessor возвращает токен, выданный вместо oldTokenId, если ротация была
+// не раньше within назад и новый токен еще не обменян. Иначе sql.ErrNoRows.
+func (r *AuthRepository) GetRefreshTokenSuccessor(ctx context.Context, oldTokenId int, within time.Duration) (domain.RefreshToken, error) {
+	var successor domain.RefreshToken
+	query := `SELECT n.* FROM user_refresh_tokens o
+	          JOIN user_refresh_tokens n ON n.id = o.replaced_by
+	          WHERE o.id=$1 AND o.rotated_at > NOW() - make_interval(secs => $2) AND n.rotated_at IS NULL`
+	err := r.db.GetContext(ctx, &successor, query, oldTokenId, within.Seconds())
+	return successor, err
+}
+
+// DeleteRotatedRefreshTokens удаляет ротированные токены старше olderThan: они уже истекли
+// и для обнаружения повторного использования не нужны
+func (r *AuthRepository) DeleteRotatedRefreshTokens(ctx context.Context, olderThan time.Duration) (int64, error) {
+	query := "DELETE FROM user_refresh_tokens WHERE rotated_at < NOW() - make_interval(secs => $1)"
+	result, err := r.db.ExecContext(ctx, query, olderThan.Seconds())
+	if err != nil {
+		return 0, err
+	}
+	return result.RowsAffected()
 }
 
-func (r *AuthRepository) DeleteRefreshToken(tokenId int) error {
+func (r *AuthRepository) DeleteRefreshToken(ctx context.Context, tokenId int) error {
 	query := "DELETE FROM user_refresh_tokens WHERE id=$1"
-	_, err := r.db.Exec(query, tokenId)
+	_, err := r.db.ExecContext(ctx, query, tokenId)
 	return err
 }
 
-func (r *AuthRepository) DeleteAllUserRefreshTokens(userId int) error {
+// UpdateRefreshTokenName переименовывает устройство у активного токена семейства
+func (r *AuthRepository) UpdateRefreshTokenName(ctx context.Context, familyId, name string) error {
+	query := "UPDATE user_refresh_tokens SET name_device=$1 WHERE family_id=$2 AND rotated_at IS NULL"
+	_, err := r.db.ExecContext(ctx, query, name, familyId)
+	return err
+}
+
+func (r *AuthRepository) DeleteRefreshTokenFamily(ctx context.Context, familyId string) error {
+	query := "DELETE FROM user_refresh_tokens WHERE family_id=$1"
+	_, err := r.db.ExecContext(ctx, query, familyId)
+	return err
+}
+
+func (r *AuthRepository) DeleteAllUserRefreshTokens(ctx context.Context, userId int) error {
 	query := "DELETE FROM user_refresh_tokens WHERE user_id=$1"
-	_, err := r.db.Exec(query, userId)
+	_, err := r.db.ExecContext(ctx, query, userId)
 	return err
 }
 
-func (r *AuthRepository) GetRefreshTokens(userId int) ([]domain.RefreshToken, error) {
+func (r *AuthRepository) GetRefreshTokens(ctx context.Context, userId int) ([]domain.RefreshToken, error) {
 	var refresh []domain.RefreshToken
-	query := "SELECT * FROM user_refresh_tokens WHERE user_id=$1"
-	err := r.db.Select(&refresh, query, userId)
+	query := "SELECT * FROM user_refresh_tokens WHERE user_id=$1 AND rotated_at IS NULL ORDER BY last_used_at DESC"
+	err := r.db.SelectContext(ctx, &refresh, query, userId)
 	return refresh, err
 }
 
-// CreateOAuthUser creates a new user with OAuth credentials
-func (r *AuthRepository) CreateOAuthUser(user domain.User) (int, error) {
+// CreateOAuthUser создает пользователя без пароля вместе с первой привязкой к провайдеру.
+// Пустой email сохраняется как NULL.
+func (r *AuthRepository) CreateOAuthUser(ctx context.Context, user domain.User, identity domain.Identity) (int, error) {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return 0, err
+	}
+	defer tx.Rollback()
+
 	var id int
-	query := `INSERT INTO users (email, password_hash, oauth_provider, oauth_id) 
-	          VALUES ($1, $2, $3, $4) RETURNING id`
-	row := r.db.QueryRow(query, user.Email, user.Password, user.OAuthProvider, user.OAuthID)
-	if err := row.Scan(&id); err != nil {
+	query := "INSERT INTO users (email, email_verified) VALUES (NULLIF($1, ''), $2) RETURNING id"
+	if err := tx.QueryRowContext(ctx, query, user.Email, user.EmailVerified).Scan(&id); err != nil {
 		return 0, err
 	}
-	return id, nil
+
+	query = `INSERT INTO user_identities (user_id, provider, provider_user_id, email, last_used_at)
+	         VALUES ($1, $2, $3, $4, NOW())`
+	if _, err := tx.ExecContext(ctx, query, id, identity.Provider, identity.ProviderUserID, identity.Email); err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
 }
 
-// GetUserByOAuth finds a user by OAuth provider and OAuth ID
-func (r *AuthRepository) GetUserByOAuth(provider, oauthID string) (domain.User, error) {
+// GetUserByEmail finds a user by email address (password_hash is empty for OAuth-only users)
+func (r *AuthRepository) GetUserByEmail(ctx context.Context, email string) (domain.User, error) {
 	var user domain.User
-	query := "SELECT id, email, oauth_provider, oauth_id FROM users WHERE oauth_provider=$1 AND oauth_id=$2"
-	err := r.db.Get(&user, query, provider, oauthID)
+	query := "SELECT id, email, COALESCE(password_hash, '') AS password_hash, email_verified FROM users WHERE email=$1"
+	err := r.db.GetContext(ctx, &user, query, email)
 	return user, err
 }
 
-// GetUserByEmail finds a user by email address
-func (r *AuthRepository) GetUserByEmail(email string) (domain.User, error) {
-	var user domain.User
-	query := "SELECT id, email, oauth_provider, oauth_id FROM users WHERE email=$1"
-	err := r.db.Get(&user, query, email)
-	return user, err
+// CreateSecurityEvent записывает событие в журнал безопасности
+func (r *AuthRepository) CreateSecurityEvent(ctx context.Context, event domain.SecurityEvent) error {
+	details, err := json.Marshal(event.Details)
+	if err != nil {
+		return err
+	}
+	query := "INSERT INTO security_events (user_id, event_type, details) VALUES ($1, $2, $3)"
+	_, err = r.db.ExecContext(ctx, query, event.UserID, event.Type, details)
+	return err
 }
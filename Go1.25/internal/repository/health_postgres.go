@@ -0,0 +1,69 @@
+package repository
+
+import (
+	"context"
+	"database/sql"
+	"errors"
+	"io/fs"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	localMigrate "github.com/ArtemChadaev/SeeThisGame/migrate"
+	"github.com/jmoiron/sqlx"
+)
+
+type HealthRepository struct {
+	db *sqlx.DB
+
+	latestOnce sync.Once
+	latest     uint
+	latestErr  error
+}
+
+func NewHealthPostgres(db *sqlx.DB) *HealthRepository {
+	return &HealthRepository{db: db}
+}
+
+func (r *HealthRepository) Ping(ctx context.Context) error {
+	return r.db.PingContext(ctx)
+}
+
+// MigrationVersion читает таблицу golang-migrate. Ожидаемая версия — номер последней
+// миграции, встроенной в бинарник: после деплоя новой версии они должны совпасть.
+func (r *HealthRepository) MigrationVersion(ctx context.Context) (domain.MigrationVersion, error) {
+	r.latestOnce.Do(func() {
+		r.latest, r.latestErr = latestMigrationVersion()
+	})
+	if r.latestErr != nil {
+		return domain.MigrationVersion{}, r.latestErr
+	}
+
+	version := domain.MigrationVersion{Expected: r.latest}
+	query := `SELECT version, dirty FROM schema_migrations LIMIT 1`
+	err := r.db.QueryRowContext(ctx, query).Scan(&version.Current, &version.Dirty)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return domain.MigrationVersion{}, err
+	}
+	return version, nil
+}
+
+// latestMigrationVersion — наибольший номер из имен файлов 000013_name.up.sql
+func latestMigrationVersion() (uint, error) {
+	files, err := fs.Glob(localMigrate.FS, "*.up.sql")
+	if err != nil {
+		return 0, err
+	}
+
+	var latest uint
+	for _, file := range files {
+		number, _, _ := strings.Cut(file, "_")
+		version, err := strconv.ParseUint(number, 10, 64)
+		if err != nil {
+			return 0, err
+		}
+		latest = max(latest, uint(version))
+	}
+	return latest, nil
+}
@@ -0,0 +1,54 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+type IdentityRepository struct {
+	db *sqlx.DB
+}
+
+func NewIdentityPostgres(db *sqlx.DB) *IdentityRepository {
+	return &IdentityRepository{db: db}
+}
+
+func (r *IdentityRepository) GetIdentity(ctx context.Context, provider, providerUserId string) (domain.Identity, error) {
+	var identity domain.Identity
+	query := "SELECT * FROM user_identities WHERE provider=$1 AND provider_user_id=$2"
+	err := r.db.GetContext(ctx, &identity, query, provider, providerUserId)
+	return identity, err
+}
+
+func (r *IdentityRepository) GetIdentities(ctx context.Context, userId int) ([]domain.Identity, error) {
+	var identities []domain.Identity
+	query := "SELECT * FROM user_identities WHERE user_id=$1 ORDER BY created_at"
+	err := r.db.SelectContext(ctx, &identities, query, userId)
+	return identities, err
+}
+
+func (r *IdentityRepository) CreateIdentity(ctx context.Context, identity domain.Identity) error {
+	query := `INSERT INTO user_identities (user_id, provider, provider_user_id, email, last_used_at)
+	          VALUES ($1, $2, $3, $4, NOW())`
+	_, err := r.db.ExecContext(ctx, query, identity.UserID, identity.Provider, identity.ProviderUserID, identity.Email)
+	return err
+}
+
+func (r *IdentityRepository) TouchIdentity(ctx context.Context, id int) error {
+	query := "UPDATE user_identities SET last_used_at=NOW() WHERE id=$1"
+	_, err := r.db.ExecContext(ctx, query, id)
+	return err
+}
+
+// DeleteIdentity отвязывает провайдера. false — такой привязки у пользователя нет.
+func (r *IdentityRepository) DeleteIdentity(ctx context.Context, userId int, provider string) (bool, error) {
+	query := "DELETE FROM user_identities WHERE user_id=$1 AND provider=$2"
+	result, err := r.db.ExecContext(ctx, query, userId, provider)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected == 1, err
+}
@@ -0,0 +1,92 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+type MFARepository struct {
+	db *sqlx.DB
+}
+
+func NewMFAPostgres(db *sqlx.DB) *MFARepository {
+	return &MFARepository{db: db}
+}
+
+// SaveTOTPSecret сохраняет новый секрет. Подтвержденный TOTP перезаписать нельзя — сначала его надо отключить.
+func (r *MFARepository) SaveTOTPSecret(ctx context.Context, userId int, secret string) error {
+	query := `INSERT INTO user_totp (user_id, secret) VALUES ($1, $2)
+	          ON CONFLICT (user_id) DO UPDATE SET secret=EXCLUDED.secret, last_used_step=0, created_at=NOW()
+	          WHERE user_totp.enabled=false`
+	_, err := r.db.ExecContext(ctx, query, userId, secret)
+	return err
+}
+
+func (r *MFARepository) GetTOTP(ctx context.Context, userId int) (domain.TOTP, error) {
+	var totp domain.TOTP
+	query := "SELECT * FROM user_totp WHERE user_id=$1"
+	err := r.db.GetContext(ctx, &totp, query, userId)
+	return totp, err
+}
+
+func (r *MFARepository) EnableTOTP(ctx context.Context, userId int) error {
+	query := "UPDATE user_totp SET enabled=true, confirmed_at=NOW() WHERE user_id=$1"
+	_, err := r.db.ExecContext(ctx, query, userId)
+	return err
+}
+
+// UpdateTOTPLastStep запоминает использованный шаг. false — код с этим шагом уже использовали.
+func (r *MFARepository) UpdateTOTPLastStep(ctx context.Context, userId int, step int64) (bool, error) {
+	query := "UPDATE user_totp SET last_used_step=$1 WHERE user_id=$2 AND last_used_step < $1"
+	result, err := r.db.ExecContext(ctx, query, step, userId)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected == 1, err
+}
+
+func (r *MFARepository) DeleteTOTP(ctx context.Context, userId int) error {
+	query := "DELETE FROM user_totp WHERE user_id=$1"
+	_, err := r.db.ExecContext(ctx, query, userId)
+	return err
+}
+
+// ReplaceRecoveryCodes удаляет старые коды и сохраняет новые в одной транзакции
+func (r *MFARepository) ReplaceRecoveryCodes(ctx context.Context, userId int, codeHashes []string) error {
+	tx, err := r.db.BeginTxx(ctx, nil)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.ExecContext(ctx, "DELETE FROM user_recovery_codes WHERE user_id=$1", userId); err != nil {
+		return err
+	}
+	for _, hash := range codeHashes {
+		if _, err := tx.ExecContext(ctx, "INSERT INTO user_recovery_codes (user_id, code_hash) VALUES ($1, $2)", userId, hash); err != nil {
+			return err
+		}
+	}
+
+	return tx.Commit()
+}
+
+// UseRecoveryCode погашает код. false — кода нет или он уже использован.
+func (r *MFARepository) UseRecoveryCode(ctx context.Context, userId int, codeHash string) (bool, error) {
+	query := "UPDATE user_recovery_codes SET used_at=NOW() WHERE user_id=$1 AND code_hash=$2 AND used_at IS NULL"
+	result, err := r.db.ExecContext(ctx, query, userId, codeHash)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected > 0, err
+}
+
+func (r *MFARepository) DeleteRecoveryCodes(ctx context.Context, userId int) error {
+	query := "DELETE FROM user_recovery_codes WHERE user_id=$1"
+	_, err := r.db.ExecContext(ctx, query, userId)
+	return err
+}
@@ -0,0 +1,52 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/jmoiron/sqlx"
+)
+
+type PasskeyRepository struct {
+	db *sqlx.DB
+}
+
+func NewPasskeyPostgres(db *sqlx.DB) *PasskeyRepository {
+	return &PasskeyRepository{db: db}
+}
+
+func (r *PasskeyRepository) CreatePasskey(ctx context.Context, passkey domain.Passkey) (int, error) {
+	var id int
+	query := `INSERT INTO user_passkeys (user_id, credential_id, credential, name)
+	          VALUES ($1, $2, $3, $4) RETURNING id`
+	row := r.db.QueryRowContext(ctx, query, passkey.UserID, passkey.CredentialID, passkey.Credential, passkey.Name)
+	if err := row.Scan(&id); err != nil {
+		return 0, err
+	}
+	return id, nil
+}
+
+func (r *PasskeyRepository) GetPasskeys(ctx context.Context, userId int) ([]domain.Passkey, error) {
+	var passkeys []domain.Passkey
+	query := "SELECT * FROM user_passkeys WHERE user_id=$1 ORDER BY created_at"
+	err := r.db.SelectContext(ctx, &passkeys, query, userId)
+	return passkeys, err
+}
+
+// UpdatePasskeyCredential сохраняет запись после входа (счетчик подписей, флаги) и время использования
+func (r *PasskeyRepository) UpdatePasskeyCredential(ctx context.Context, id int, credential []byte) error {
+	query := "UPDATE user_passkeys SET credential=$1, last_used_at=NOW() WHERE id=$2"
+	_, err := r.db.ExecContext(ctx, query, credential, id)
+	return err
+}
+
+// DeletePasskey удаляет ключ пользователя. false — ключа нет или он чужой.
+func (r *PasskeyRepository) DeletePasskey(ctx context.Context, userId, id int) (bool, error) {
+	query := "DELETE FROM user_passkeys WHERE id=$1 AND user_id=$2"
+	result, err := r.db.ExecContext(ctx, query, id, userId)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected == 1, err
+}
@@ -3,6 +3,7 @@ package repository
 import (
 	"context"
 
+	"github.com/redis/go-redis/extra/redisotel/v9"
 	"github.com/redis/go-redis/v9"
 )
 
@@ -23,5 +24,10 @@ func NewRedisClient(cfg RedisConfig) (*redis.Client, error) {
 		return nil, err
 	}
 
+	// Спан на каждую команду и pipeline
+	if err := redisotel.InstrumentTracing(rdb); err != nil {
+		return nil, err
+	}
+
 	return rdb, nil
 }
@@ -9,6 +9,13 @@ import (
 type Repository struct {
 	domain.AuthorizationRepository
 	domain.UserSettingsRepository
+	domain.MFARepository
+	domain.PasskeyRepository
+	domain.IdentityRepository
+	domain.RoleRepository
+	domain.AccountRepository
+	domain.APITokenRepository
+	domain.HealthRepository
 }
 
 func NewRepository(db *sqlx.DB) *Repository {
@@ -16,5 +23,12 @@ func NewRepository(db *sqlx.DB) *Repository {
 		// Здесь мы инициализируем конкретные реализации (например, из postgres)
 		AuthorizationRepository: NewAuthPostgres(db),
 		UserSettingsRepository:  NewUserSettingsPostgres(db),
+		MFARepository:           NewMFAPostgres(db),
+		PasskeyRepository:       NewPasskeyPostgres(db),
+		IdentityRepository:      NewIdentityPostgres(db),
+		RoleRepository:          NewRolePostgres(db),
+		AccountRepository:       NewAccountPostgres(db),
+		APITokenRepository:      NewAPITokenPostgres(db),
+		HealthRepository:        NewHealthPostgres(db),
 	}
 }
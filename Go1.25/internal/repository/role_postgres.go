@@ -0,0 +1,83 @@
+package repository
+
+import (
+	"context"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/jmoiron/sqlx"
+	"github.com/lib/pq"
+)
+
+type RoleRepository struct {
+	db *sqlx.DB
+}
+
+func NewRolePostgres(db *sqlx.DB) *RoleRepository {
+	return &RoleRepository{db: db}
+}
+
+// GetRoles возвращает все роли вместе с их правами
+func (r *RoleRepository) GetRoles(ctx context.Context) ([]domain.Role, error) {
+	var rows []struct {
+		ID          int            `db:"id"`
+		Name        string         `db:"name"`
+		Description *string        `db:"description"`
+		Permissions pq.StringArray `db:"permissions"`
+	}
+	query := `SELECT r.id, r.name, r.description,
+	                 COALESCE(array_agg(rp.permission ORDER BY rp.permission)
+	                          FILTER (WHERE rp.permission IS NOT NULL), '{}') AS permissions
+	          FROM roles r
+	          LEFT JOIN role_permissions rp ON rp.role_id = r.id
+	          GROUP BY r.id
+	          ORDER BY r.id`
+	if err := r.db.SelectContext(ctx, &rows, query); err != nil {
+		return nil, err
+	}
+
+	roles := make([]domain.Role, 0, len(rows))
+	for _, row := range rows {
+		roles = append(roles, domain.Role{
+			ID:          row.ID,
+			Name:        row.Name,
+			Description: row.Description,
+			Permissions: row.Permissions,
+		})
+	}
+	return roles, nil
+}
+
+func (r *RoleRepository) GetUserRoles(ctx context.Context, userId int) ([]string, error) {
+	var roles []string
+	query := `SELECT r.name FROM user_roles ur
+	          JOIN roles r ON r.id = ur.role_id
+	          WHERE ur.user_id=$1
+	          ORDER BY r.name`
+	err := r.db.SelectContext(ctx, &roles, query, userId)
+	return roles, err
+}
+
+// AddUserRole выдает роль. false — роль уже выдана (или такой роли нет).
+func (r *RoleRepository) AddUserRole(ctx context.Context, userId int, role string, grantedBy int) (bool, error) {
+	query := `INSERT INTO user_roles (user_id, role_id, granted_by)
+	          SELECT $1, id, $3 FROM roles WHERE name=$2
+	          ON CONFLICT DO NOTHING`
+	result, err := r.db.ExecContext(ctx, query, userId, role, grantedBy)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected == 1, err
+}
+
+// DeleteUserRole снимает роль. false — у пользователя этой роли нет.
+func (r *RoleRepository) DeleteUserRole(ctx context.Context, userId int, role string) (bool, error) {
+	query := `DELETE FROM user_roles
+	          WHERE user_id=$1 AND role_id=(SELECT id FROM roles WHERE name=$2)`
+	result, err := r.db.ExecContext(ctx, query, userId, role)
+	if err != nil {
+		return false, err
+	}
+	rowsAffected, err := result.RowsAffected()
+	return rowsAffected == 1, err
+}
@@ -1,6 +1,7 @@
 package repository
 
 import (
+	"context"
 	"time"
 
 	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
@@ -15,44 +16,44 @@ func NewUserSettingsPostgres(db *sqlx.DB) *UserSettingsRepository {
 	return &UserSettingsRepository{db: db}
 }
 
-func (r *UserSettingsRepository) CreateUserSettings(settings domain.UserSettings) error {
-	// Используем поля .UserID и .Name из domain.UserSettings
-	query := "INSERT INTO user_settings (user_id, name) VALUES ($1, $2)"
-	_, err := r.db.Exec(query, settings.UserID, settings.Name)
+func (r *UserSettingsRepository) CreateUserSettings(ctx context.Context, settings domain.UserSettings) error {
+	// Используем поля .UserID, .Name и .Icon из domain.UserSettings
+	query := "INSERT INTO user_settings (user_id, name, icon) VALUES ($1, $2, $3)"
+	_, err := r.db.ExecContext(ctx, query, settings.UserID, settings.Name, settings.Icon)
 	return err
 }
 
-func (r *UserSettingsRepository) GetUserSettings(userId int) (domain.UserSettings, error) {
+func (r *UserSettingsRepository) GetUserSettings(ctx context.Context, userId int) (domain.UserSettings, error) {
 	var settings domain.UserSettings // Заменили rest на UserSettings
 	query := "SELECT * FROM user_settings WHERE user_id=$1"
-	err := r.db.Get(&settings, query, userId)
+	err := r.db.GetContext(ctx, &settings, query, userId)
 	return settings, err
 }
 
-func (r *UserSettingsRepository) UpdateUserSettings(settings domain.UserSettings) error {
+func (r *UserSettingsRepository) UpdateUserSettings(ctx context.Context, settings domain.UserSettings) error {
 	// Используем экспортируемые поля: .Name, .Icon, .UserID
 	query := "UPDATE user_settings SET name=$1, icon=$2 WHERE user_id=$3"
-	_, err := r.db.Exec(query, settings.Name, settings.Icon, settings.UserID)
+	_, err := r.db.ExecContext(ctx, query, settings.Name, settings.Icon, settings.UserID)
 	return err
 }
 
-func (r *UserSettingsRepository) UpdateUserCoin(userId int, coin int) error {
+func (r *UserSettingsRepository) UpdateUserCoin(ctx context.Context, userId int, coin int) error {
 	query := "UPDATE user_settings SET coin=$1 WHERE user_id=$2"
-	_, err := r.db.Exec(query, coin, userId)
+	_, err := r.db.ExecContext(ctx, query, coin, userId)
 	return err
 }
 
-func (r *UserSettingsRepository) BuyPaidSubscription(userId int, time time.Time) error {
+func (r *UserSettingsRepository) BuyPaidSubscription(ctx context.Context, userId int, time time.Time) error {
 	query := "UPDATE user_settings SET paid_subscription=$1, date_of_paid_subscription=$2 WHERE user_id=$3"
-	_, err := r.db.Exec(query, true, time, userId)
+	_, err := r.db.ExecContext(ctx, query, true, time, userId)
 	return err
 }
 
-func (r *UserSettingsRepository) DeactivateExpiredSubscriptions() (int64, error) {
+func (r *UserSettingsRepository) DeactivateExpiredSubscriptions(ctx context.Context) (int64, error) {
 	query := `UPDATE user_settings SET paid_subscription = false 
             WHERE paid_subscription = true AND date_of_paid_subscription < NOW()`
 
-	result, err := r.db.Exec(query)
+	result, err := r.db.ExecContext(ctx, query)
 	if err != nil {
 		return 0, err
 	}
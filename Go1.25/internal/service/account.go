@@ -0,0 +1,304 @@
+package service
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/ArtemChadaev/SeeThisGame/internal/logger"
+	"github.com/ArtemChadaev/SeeThisGame/internal/metrics"
+	"github.com/lib/pq"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+)
+
+// deleteScheduledAccountsInterval — как часто удаляются аккаунты с истекшим сроком
+const deleteScheduledAccountsInterval = time.Hour
+
+// deleteAccountReauthWindow — сколько после входа аккаунт без пароля можно удалить без повторного входа
+const deleteAccountReauthWindow = 10 * time.Minute
+
+type AccountService struct {
+	repo         domain.AccountRepository
+	authRepo     domain.AuthorizationRepository
+	apiTokenRepo domain.APITokenRepository
+	authService  *AuthService
+	oauthService *OAuthService
+	mfaService   *MFAService // Код второго фактора при удалении аккаунта
+	gracePeriod  time.Duration
+	// guestTTL — через сколько без входа гостевой аккаунт удаляется
+	guestTTL time.Duration
+}
+
+func NewAccountService(repo domain.AccountRepository, authRepo domain.AuthorizationRepository, apiTokenRepo domain.APITokenRepository, authService *AuthService, oauthService *OAuthService, mfaService *MFAService) *AccountService {
+	gracePeriod := viper.GetDuration("account.deletionGracePeriod")
+	if gracePeriod <= 0 {
+		gracePeriod = 30 * 24 * time.Hour
+	}
+	guestTTL := viper.GetDuration("account.guestTTL")
+	if guestTTL <= 0 {
+		guestTTL = 30 * 24 * time.Hour
+	}
+
+	service := &AccountService{
+		repo:         repo,
+		authRepo:     authRepo,
+		apiTokenRepo: apiTokenRepo,
+		authService:  authService,
+		oauthService: oauthService,
+		mfaService:   mfaService,
+		gracePeriod:  gracePeriod,
+		guestTTL:     guestTTL,
+	}
+
+	// Запускаем фоновую задачу окончательного удаления
+	go service.startDeletionWorker()
+
+	return service
+}
+
+// ExportAccount собирает zip архив: по JSON файлу на каждый раздел данных
+// и export.json с описанием выгрузки.
+func (s *AccountService) ExportAccount(ctx context.Context, userId int) (domain.AccountExport, error) {
+	ctx, span := tracer.Start(ctx, "AccountService.ExportAccount")
+	defer span.End()
+
+	sections, err := s.repo.ExportUserData(ctx, userId)
+	if err != nil {
+		return domain.AccountExport{}, domain.NewInternalServerError(err)
+	}
+
+	names := make([]string, 0, len(sections))
+	for name := range sections {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	now := time.Now().UTC()
+	manifest, err := json.MarshalIndent(map[string]any{
+		"userId":     userId,
+		"exportedAt": now,
+		"sections":   names,
+	}, "", "  ")
+	if err != nil {
+		return domain.AccountExport{}, domain.NewInternalServerError(err)
+	}
+
+	var buf bytes.Buffer
+	archive := zip.NewWriter(&buf)
+	if err := writeZipFile(archive, "export.json", manifest); err != nil {
+		return domain.AccountExport{}, domain.NewInternalServerError(err)
+	}
+	for _, name := range names {
+		var pretty bytes.Buffer
+		if err := json.Indent(&pretty, sections[name], "", "  "); err != nil {
+			return domain.AccountExport{}, domain.NewInternalServerError(err)
+		}
+		if err := writeZipFile(archive, name+".json", pretty.Bytes()); err != nil {
+			return domain.AccountExport{}, domain.NewInternalServerError(err)
+		}
+	}
+	if err := archive.Close(); err != nil {
+		return domain.AccountExport{}, domain.NewInternalServerError(err)
+	}
+
+	logger.FromContext(ctx).Infof("personal data exported for user %d", userId)
+
+	return domain.AccountExport{
+		FileName: fmt.Sprintf("seethisgame-export-%d-%s.zip", userId, now.Format("20060102")),
+		Data:     buf.Bytes(),
+	}, nil
+}
+
+// DeleteAccount планирует удаление аккаунта через gracePeriod и завершает все сессии.
+// Пароль проверяется, если он задан; вход до наступления срока отменяет удаление.
+func (s *AccountService) DeleteAccount(ctx context.Context, userId int, sessionId, password, code string, device domain.DeviceInfo) (time.Time, error) {
+	ctx, span := tracer.Start(ctx, "AccountService.DeleteAccount")
+	defer span.End()
+
+	user, err := s.authRepo.GetUserById(ctx, userId)
+	if err != nil {
+		return time.Time{}, domain.ErrUserNotFound
+	}
+
+	// Одного access токена мало: с паролем — пароль, без пароля (OAuth, Telegram, ключ доступа) —
+	// вход не раньше deleteAccountReauthWindow назад. Гостю войти заново нечем, и терять ему,
+	// кроме самого гостевого аккаунта, нечего — ему хватает токена.
+	switch {
+	case user.Password != "":
+		if err := s.checkPassword(ctx, user, password, device); err != nil {
+			return time.Time{}, err
+		}
+	case user.IsGuest:
+	default:
+		if err := s.checkRecentSignIn(ctx, userId, sessionId); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	if err := s.mfaService.requireSecondFactor(ctx, userId, code); err != nil {
+		return time.Time{}, err
+	}
+
+	deleteAt := time.Now().Add(s.gracePeriod)
+	if err := s.repo.ScheduleAccountDeletion(ctx, userId, deleteAt); err != nil {
+		return time.Time{}, domain.NewInternalServerError(err)
+	}
+
+	// Токены аккаунта, ожидающего удаления, и так не принимаются, но после отмены удаления
+	// не должны заработать снова
+	if _, err := s.apiTokenRepo.DeleteAllUserAPITokens(ctx, userId); err != nil {
+		return time.Time{}, domain.NewInternalServerError(err)
+	}
+
+	if err := s.authService.UnAuthorizeAll(ctx, userId); err != nil {
+		return time.Time{}, err
+	}
+
+	logger.FromContext(ctx).Infof("account deletion scheduled for user %d at %s", userId, deleteAt.Format(time.RFC3339))
+	return deleteAt, nil
+}
+
+// checkPassword проверяет пароль с теми же блокировками, что и вход: иначе украденный
+// access токен позволил бы перебирать пароль без ограничений.
+func (s *AccountService) checkPassword(ctx context.Context, user domain.User, password string, device domain.DeviceInfo) error {
+	if err := s.authService.checkLoginLock(ctx, user.Email); err != nil {
+		return err
+	}
+
+	ok, _, err := verifyPassword(password, user.Password)
+	if err != nil {
+		return domain.NewInternalServerError(err)
+	}
+	if !ok {
+		s.authService.registerLoginFailure(ctx, user.Email, device)
+		return domain.ErrInvalidCredentials
+	}
+	s.authService.resetLoginFailures(ctx, user.Email)
+	return nil
+}
+
+// checkRecentSignIn проверяет, что сессия sessionId начата входом не раньше deleteAccountReauthWindow назад.
+// Время начала сессии переносится при ротации refresh токена, поэтому обновление токена его не сдвигает.
+func (s *AccountService) checkRecentSignIn(ctx context.Context, userId int, sessionId string) error {
+	if sessionId == "" {
+		return domain.ErrReauthenticationRequired
+	}
+
+	sessions, err := s.authRepo.GetRefreshTokens(ctx, userId)
+	if err != nil {
+		return domain.NewInternalServerError(err)
+	}
+	for _, session := range sessions {
+		if session.FamilyID == sessionId && time.Since(session.CreatedAt) <= deleteAccountReauthWindow {
+			return nil
+		}
+	}
+	return domain.ErrReauthenticationRequired
+}
+
+// UpgradeGuest превращает гостя в обычный аккаунт с email и паролем.
+// id пользователя не меняется, поэтому прогресс, монеты и текущие сессии сохраняются.
+func (s *AccountService) UpgradeGuest(ctx context.Context, userId int, email, password string) error {
+	ctx, span := tracer.Start(ctx, "AccountService.UpgradeGuest")
+	defer span.End()
+
+	if err := s.checkGuest(ctx, userId); err != nil {
+		return err
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return domain.NewInternalServerError(err)
+	}
+
+	upgraded, err := s.authRepo.UpgradeGuestUser(ctx, userId, email, hash)
+	if err != nil {
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return domain.ErrUserAlreadyExists
+		}
+		return domain.NewInternalServerError(err)
+	}
+	if !upgraded {
+		return domain.ErrNotGuest
+	}
+
+	logger.FromContext(ctx).Infof("guest account %d upgraded with email", userId)
+	return nil
+}
+
+// BeginGuestUpgrade начинает привязку провайдера к гостю. Аккаунт станет обычным
+// после успешного callback (см. OAuthService.HandleCallback).
+func (s *AccountService) BeginGuestUpgrade(ctx context.Context, userId int, provider string) (string, string, error) {
+	ctx, span := tracer.Start(ctx, "AccountService.BeginGuestUpgrade")
+	defer span.End()
+
+	if err := s.checkGuest(ctx, userId); err != nil {
+		return "", "", err
+	}
+	return s.oauthService.GetLinkURL(ctx, userId, provider)
+}
+
+func (s *AccountService) checkGuest(ctx context.Context, userId int) error {
+	user, err := s.authRepo.GetUserById(ctx, userId)
+	if err != nil {
+		return domain.ErrUserNotFound
+	}
+	if !user.IsGuest {
+		return domain.ErrNotGuest
+	}
+	return nil
+}
+
+// startDeletionWorker — фоновый процесс окончательного удаления аккаунтов
+// и заброшенных гостевых аккаунтов. Данные в остальных таблицах удаляются каскадом (ON DELETE CASCADE).
+func (s *AccountService) startDeletionWorker() {
+	ticker := time.NewTicker(deleteScheduledAccountsInterval)
+	defer ticker.Stop()
+
+	logrus.Infof("Фоновая задача: удаление аккаунтов каждые %v", deleteScheduledAccountsInterval)
+
+	for range ticker.C {
+		start := time.Now()
+		metrics.ObserveJob(metrics.JobAccountDeletion, start, s.deleteExpiredAccounts(context.Background()))
+	}
+}
+
+// deleteExpiredAccounts — один запуск startDeletionWorker
+func (s *AccountService) deleteExpiredAccounts(ctx context.Context) error {
+	ids, err := s.repo.DeleteScheduledAccounts(ctx)
+	if err != nil {
+		logrus.Errorf("Ошибка при удалении аккаунтов: %v", err)
+		return err
+	}
+
+	for _, id := range ids {
+		logrus.Infof("account %d deleted after grace period", id)
+	}
+
+	guests, err := s.repo.DeleteStaleGuests(ctx, s.guestTTL)
+	if err != nil {
+		logrus.Errorf("Ошибка при удалении гостевых аккаунтов: %v", err)
+		return err
+	}
+	if len(guests) > 0 {
+		logrus.Infof("Удалено %d заброшенных гостевых аккаунтов", len(guests))
+	}
+	return nil
+}
+
+func writeZipFile(archive *zip.Writer, name string, data []byte) error {
+	file, err := archive.Create(name)
+	if err != nil {
+		return err
+	}
+	_, err = file.Write(data)
+	return err
+}
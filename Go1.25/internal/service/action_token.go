@@ -0,0 +1,135 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// Назначения одноразовых токенов. Токен одного назначения нельзя использовать для другого.
+const (
+	actionEmailVerification = "email_verification"
+	actionPasswordReset     = "password_reset"
+)
+
+// consumeActionTokenScript атомарно удаляет nonce, только если он совпадает с ожидаемым.
+var consumeActionTokenScript = redis.NewScript(`
+if redis.call("GET", KEYS[1]) == ARGV[1] then
+	return redis.call("DEL", KEYS[1])
+end
+return 0
+`)
+
+type actionTokenPayload struct {
+	Purpose string `json:"p"`
+	UserId  int    `json:"u"`
+	Nonce   string `json:"n"`
+	Expires int64  `json:"x"`
+}
+
+// actionTokens выдает подписанные HMAC одноразовые токены для ссылок из писем.
+// Подпись защищает от подделки, а nonce в Redis делает токен одноразовым:
+// при повторной выдаче для того же пользователя и назначения старый токен перестает работать.
+type actionTokens struct {
+	secret []byte
+	redis  *redis.Client
+}
+
+func newActionTokens(redis *redis.Client) *actionTokens {
+	secret := []byte(os.Getenv("ACTION_TOKEN_SECRET"))
+	if len(secret) == 0 {
+		logrus.Warn("ACTION_TOKEN_SECRET is empty, using ephemeral secret for email tokens")
+		secret = make([]byte, 32)
+		if _, err := rand.Read(secret); err != nil {
+			panic(err)
+		}
+	}
+
+	return &actionTokens{secret: secret, redis: redis}
+}
+
+func actionTokenKey(purpose string, userId int) string {
+	return "action_token:" + purpose + ":" + strconv.Itoa(userId)
+}
+
+// Issue создает токен для userId со сроком жизни ttl.
+func (t *actionTokens) Issue(ctx context.Context, purpose string, userId int, ttl time.Duration) (string, error) {
+	nonceBytes := make([]byte, 16)
+	if _, err := rand.Read(nonceBytes); err != nil {
+		return "", err
+	}
+
+	payload := actionTokenPayload{
+		Purpose: purpose,
+		UserId:  userId,
+		Nonce:   base64.RawURLEncoding.EncodeToString(nonceBytes),
+		Expires: time.Now().Add(ttl).Unix(),
+	}
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return "", err
+	}
+
+	if err := t.redis.Set(ctx, actionTokenKey(purpose, userId), payload.Nonce, ttl).Err(); err != nil {
+		return "", err
+	}
+
+	encoded := base64.RawURLEncoding.EncodeToString(payloadJSON)
+	return encoded + "." + base64.RawURLEncoding.EncodeToString(t.sign(encoded)), nil
+}
+
+// Consume проверяет токен и погашает его. Возвращает userId владельца.
+func (t *actionTokens) Consume(ctx context.Context, purpose, token string) (int, error) {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return 0, domain.ErrInvalidToken
+	}
+
+	expected, err := base64.RawURLEncoding.DecodeString(signature)
+	if err != nil || !hmac.Equal(expected, t.sign(encoded)) {
+		return 0, domain.ErrInvalidToken
+	}
+
+	payloadJSON, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return 0, domain.ErrInvalidToken
+	}
+	var payload actionTokenPayload
+	if err := json.Unmarshal(payloadJSON, &payload); err != nil {
+		return 0, domain.ErrInvalidToken
+	}
+
+	if payload.Purpose != purpose || time.Now().Unix() > payload.Expires {
+		return 0, domain.ErrInvalidToken
+	}
+
+	deleted, err := consumeActionTokenScript.Run(ctx, t.redis,
+		[]string{actionTokenKey(purpose, payload.UserId)}, payload.Nonce).Int()
+	if err != nil && !errors.Is(err, redis.Nil) {
+		return 0, domain.NewInternalServerError(err)
+	}
+	if deleted == 0 {
+		// Уже использован или заменен более новым токеном
+		return 0, domain.ErrInvalidToken
+	}
+
+	return payload.UserId, nil
+}
+
+func (t *actionTokens) sign(encodedPayload string) []byte {
+	mac := hmac.New(sha256.New, t.secret)
+	mac.Write([]byte(encodedPayload))
+	return mac.Sum(nil)
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/ArtemChadaev/SeeThisGame/internal/logger"
+)
+
+const (
+	// maxAPITokens — сколько персональных токенов может быть у одного пользователя
+	maxAPITokens = 20
+	// apiTokenPrefixLength — сколько символов токена сохраняется открыто, чтобы узнать его в списке
+	apiTokenPrefixLength = 12
+)
+
+type APITokenService struct {
+	repo     domain.APITokenRepository
+	roleRepo domain.RoleRepository
+}
+
+func NewAPITokenService(repo domain.APITokenRepository, roleRepo domain.RoleRepository) *APITokenService {
+	return &APITokenService{
+		repo:     repo,
+		roleRepo: roleRepo,
+	}
+}
+
+// CreateAPIToken выпускает токен с областями scopes. ttl = 0 — бессрочный токен.
+func (s *APITokenService) CreateAPIToken(ctx context.Context, userId int, name string, scopes []string, ttl time.Duration) (domain.NewAPIToken, error) {
+	ctx, span := tracer.Start(ctx, "APITokenService.CreateAPIToken")
+	defer span.End()
+
+	for _, scope := range scopes {
+		if !slices.Contains(domain.APITokenScopes, scope) {
+			return domain.NewAPIToken{}, domain.NewInvalidRequestError(fmt.Errorf("unknown scope %q", scope))
+		}
+	}
+	slices.Sort(scopes)
+	scopes = slices.Compact(scopes)
+
+	existing, err := s.repo.GetAPITokens(ctx, userId)
+	if err != nil {
+		return domain.NewAPIToken{}, domain.NewInternalServerError(err)
+	}
+	if len(existing) >= maxAPITokens {
+		return domain.NewAPIToken{}, domain.NewInvalidRequestError(fmt.Errorf("at most %d api tokens are allowed", maxAPITokens))
+	}
+
+	secret, err := generateRefreshToken()
+	if err != nil {
+		return domain.NewAPIToken{}, domain.NewInternalServerError(err)
+	}
+	token := domain.APITokenPrefix + secret
+
+	apiToken := domain.APIToken{
+		UserID:    userId,
+		Name:      name,
+		TokenHash: hashAPIToken(token),
+		Prefix:    token[:apiTokenPrefixLength],
+		Scopes:    scopes,
+		CreatedAt: time.Now(),
+	}
+	if ttl > 0 {
+		expiresAt := apiToken.CreatedAt.Add(ttl)
+		apiToken.ExpiresAt = &expiresAt
+	}
+
+	apiToken.ID, err = s.repo.CreateAPIToken(ctx, apiToken)
+	if err != nil {
+		return domain.NewAPIToken{}, domain.NewInternalServerError(err)
+	}
+
+	logger.FromContext(ctx).Infof("api token %d created by user %d with scopes %s", apiToken.ID, userId, strings.Join(scopes, " "))
+	return domain.NewAPIToken{APIToken: apiToken, Token: token}, nil
+}
+
+func (s *APITokenService) GetAPITokens(ctx context.Context, userId int) ([]domain.APIToken, error) {
+	ctx, span := tracer.Start(ctx, "APITokenService.GetAPITokens")
+	defer span.End()
+
+	tokens, err := s.repo.GetAPITokens(ctx, userId)
+	if err != nil {
+		return nil, domain.NewInternalServerError(err)
+	}
+	return tokens, nil
+}
+
+func (s *APITokenService) DeleteAPIToken(ctx context.Context, userId, id int) error {
+	ctx, span := tracer.Start(ctx, "APITokenService.DeleteAPIToken")
+	defer span.End()
+
+	deleted, err := s.repo.DeleteAPIToken(ctx, userId, id)
+	if err != nil {
+		return domain.NewInternalServerError(err)
+	}
+	if !deleted {
+		return domain.ErrAPITokenNotFound
+	}
+
+	logger.FromContext(ctx).Infof("api token %d revoked by user %d", id, userId)
+	return nil
+}
+
+// ParseAPIToken проверяет персональный токен и возвращает данные для userIdentify.
+// Роли берутся на момент запроса: токен живет долго, а роли могут поменяться.
+func (s *APITokenService) ParseAPIToken(ctx context.Context, token string) (domain.AccessTokenClaims, error) {
+	ctx, span := tracer.Start(ctx, "APITokenService.ParseAPIToken")
+	defer span.End()
+
+	apiToken, err := s.repo.GetAPITokenByHash(ctx, hashAPIToken(token))
+	if err != nil {
+		if errors.Is(err, sql.ErrNoRows) {
+			return domain.AccessTokenClaims{}, domain.ErrInvalidToken
+		}
+		return domain.AccessTokenClaims{}, domain.NewInternalServerError(err)
+	}
+
+	if apiToken.ExpiresAt != nil && time.Now().After(*apiToken.ExpiresAt) {
+		return domain.AccessTokenClaims{}, domain.ErrInvalidToken
+	}
+
+	roles, err := s.roleRepo.GetUserRoles(ctx, apiToken.UserID)
+	if err != nil {
+		return domain.AccessTokenClaims{}, domain.NewInternalServerError(err)
+	}
+
+	if err := s.repo.TouchAPIToken(ctx, apiToken.ID); err != nil {
+		logger.FromContext(ctx).Errorf("failed to update api token %d last use: %v", apiToken.ID, err)
+	}
+
+	claims := domain.AccessTokenClaims{
+		UserID:     apiToken.UserID,
+		Roles:      roles,
+		APITokenID: apiToken.ID,
+		Scopes:     apiToken.Scopes,
+		IssuedAt:   apiToken.CreatedAt,
+	}
+	if apiToken.ExpiresAt != nil {
+		claims.ExpiresAt = *apiToken.ExpiresAt
+	}
+	return claims, nil
+}
+
+func hashAPIToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
@@ -1,52 +1,77 @@
 package service
 
 import (
+	"context"
 	"crypto/rand"
-	"crypto/sha1"
+	"database/sql"
 	"encoding/base64"
 	"errors"
-	"fmt"
 	"strings"
 	"time"
 
 	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/ArtemChadaev/SeeThisGame/internal/logger"
+	"github.com/ArtemChadaev/SeeThisGame/internal/metrics"
 	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
 	"github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
 )
 
 const (
-	salt                  = "asdagedrhftyki518sadf5as8"
-	signingKey            = "awsg8s#@4Sf86DS#$2dF"
-	accessTokenTTL        = time.Minute * 15
-	refreshTokenTTL       = time.Hour * 24 * 365
-	updateRefreshTokenTTL = time.Hour * 24 * 90
+	accessTokenTTL = time.Minute * 15
+	// RefreshTokenTTL — срок жизни refresh токена (и cookie с ним в режиме cookie)
+	RefreshTokenTTL = time.Hour * 24 * 365
+
+	// guestName — имя в профиле гостя, пока он его не сменил
+	guestName = "Гость"
+
+	// refreshReuseGracePeriod — сколько после ротации повторный обмен того же токена
+	// (две вкладки обновили токен одновременно) получает уже выданный новый токен,
+	// а не считается повторным использованием
+	refreshReuseGracePeriod = 30 * time.Second
+	// pruneRotatedTokensInterval — как часто удаляются старые ротированные refresh токены
+	pruneRotatedTokensInterval = time.Hour
 )
 
 type tokenClaims struct {
 	jwt.RegisteredClaims
 	UserId int `json:"user_id"`
+	// SessionId — семейство refresh токенов, из которого выдан access токен
+	SessionId string `json:"sid,omitempty"`
+	// Roles — роли пользователя; права по ним проверяет requirePermission
+	Roles []string `json:"roles,omitempty"`
 }
 
 type AuthService struct {
 	repo            domain.AuthorizationRepository // Используем интерфейс из domain
+	mfaRepo         domain.MFARepository           // Проверка, нужен ли второй фактор при входе
+	roleRepo        domain.RoleRepository          // Роли, которые попадают в access токен
+	accountRepo     domain.AccountRepository       // Вход отменяет запланированное удаление аккаунта
 	settingsService domain.UserSettingsService     // Ссылка на сервис настроек через интерфейс
+	keys            *JWTKeySet
+	redis           *redis.Client // Denylist отозванных access токенов и MFA challenge
 }
 
-func NewAuthService(repo domain.AuthorizationRepository, settingsService domain.UserSettingsService) *AuthService {
-	return &AuthService{
+func NewAuthService(repo domain.AuthorizationRepository, mfaRepo domain.MFARepository, roleRepo domain.RoleRepository, accountRepo domain.AccountRepository, settingsService domain.UserSettingsService, keys *JWTKeySet, redis *redis.Client) *AuthService {
+	service := &AuthService{
 		repo:            repo,
+		mfaRepo:         mfaRepo,
+		roleRepo:        roleRepo,
+		accountRepo:     accountRepo,
 		settingsService: settingsService,
+		keys:            keys,
+		redis:           redis,
 	}
-}
 
-// --- Помощники (Helpers) ---
+	go service.startRotatedTokensCleanup()
 
-func generatePasswordHash(password string) string {
-	hash := sha1.New()
-	hash.Write([]byte(password))
-	return fmt.Sprintf("%x", hash.Sum([]byte(salt)))
+	return service
 }
 
+// --- Помощники (Helpers) ---
+
 func generateRefreshToken() (string, error) {
 	tokenBytes := make([]byte, 32)
 	if _, err := rand.Read(tokenBytes); err != nil {
@@ -55,25 +80,121 @@ func generateRefreshToken() (string, error) {
 	return base64.URLEncoding.EncodeToString(tokenBytes), nil
 }
 
-func (s *AuthService) newRefreshToken(userId int) (domain.RefreshToken, error) {
+// checkCredentials ищет пользователя по email и проверяет пароль.
+// Устаревшие хеши (SHA-1 или старые параметры Argon2id) перехешируются после успешной проверки.
+func (s *AuthService) checkCredentials(ctx context.Context, email, password string) (domain.User, error) {
+	user, err := s.repo.GetUserByEmail(ctx, email)
+	if errors.Is(err, sql.ErrNoRows) {
+		// Считаем хеш даже для несуществующего email, чтобы время ответа не выдавало наличие аккаунта
+		_, _, _ = verifyPassword(password, dummyPasswordHash)
+		return domain.User{}, domain.ErrInvalidCredentials
+	}
+	if err != nil {
+		// Сбой БД — не неверный пароль: такие ошибки не должны блокировать вход
+		return domain.User{}, domain.NewInternalServerError(err)
+	}
+
+	ok, needsRehash, err := verifyPassword(password, user.Password)
+	if err != nil {
+		return domain.User{}, domain.NewInternalServerError(err)
+	}
+	if !ok {
+		return domain.User{}, domain.ErrInvalidCredentials
+	}
+
+	if needsRehash {
+		if hash, err := hashPassword(password); err != nil {
+			logger.FromContext(ctx).Errorf("failed to rehash password for user %d: %v", user.ID, err)
+		} else if err := s.repo.UpdateUserPassword(ctx, domain.User{ID: user.ID, Password: hash}); err != nil {
+			logger.FromContext(ctx).Errorf("failed to update password hash for user %d: %v", user.ID, err)
+		}
+	}
+
+	return user, nil
+}
+
+func (s *AuthService) newAccessToken(ctx context.Context, userId int, sessionId string) (string, error) {
+	roles, err := s.roleRepo.GetUserRoles(ctx, userId)
+	if err != nil {
+		return "", err
+	}
+
+	now := time.Now()
+	return s.keys.sign(&tokenClaims{
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Issuer:    s.keys.issuer,
+			ExpiresAt: jwt.NewNumericDate(now.Add(accessTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+		UserId:    userId,
+		SessionId: sessionId,
+		Roles:     roles,
+	})
+}
+
+// newRefreshToken создает токен в семействе familyId (новый вход — новое семейство).
+func (s *AuthService) newRefreshToken(userId int, familyId string, device domain.DeviceInfo) (domain.RefreshToken, error) {
 	token, err := generateRefreshToken()
 	if err != nil {
 		return domain.RefreshToken{}, err
 	}
 
-	return domain.RefreshToken{
+	refresh := domain.RefreshToken{
 		UserID:    userId,
 		Token:     token,
-		ExpiresAt: time.Now().Add(refreshTokenTTL),
-	}, nil
+		ExpiresAt: time.Now().Add(RefreshTokenTTL),
+		FamilyID:  familyId,
+	}
+	if device.UserAgent != "" {
+		userAgent := truncate(device.UserAgent, maxDeviceInfoLength)
+		refresh.DeviceInfo = &userAgent
+	}
+	if device.IP != "" {
+		ip := device.IP
+		refresh.IPAddress = &ip
+	}
+
+	return refresh, nil
+}
+
+// revokeReusedFamily вызывается, когда предъявлен уже ротированный refresh токен.
+// Значит, токен украден (или клиент сломан): отзываем всё семейство и пишем событие безопасности.
+func (s *AuthService) revokeReusedFamily(ctx context.Context, refresh domain.RefreshToken) {
+	logger.FromContext(ctx).Warnf("refresh token reuse detected: user %d, family %s", refresh.UserID, refresh.FamilyID)
+
+	if err := s.repo.DeleteRefreshTokenFamily(ctx, refresh.FamilyID); err != nil {
+		logger.FromContext(ctx).Errorf("failed to revoke refresh token family %s: %v", refresh.FamilyID, err)
+	}
+	if err := s.revokeSessionAccess(ctx, refresh.FamilyID); err != nil {
+		logger.FromContext(ctx).Errorf("failed to revoke access tokens of family %s: %v", refresh.FamilyID, err)
+	}
+
+	event := domain.SecurityEvent{
+		UserID: refresh.UserID,
+		Type:   domain.SecurityEventRefreshTokenReuse,
+		Details: map[string]string{
+			"family_id": refresh.FamilyID,
+		},
+	}
+	if err := s.repo.CreateSecurityEvent(ctx, event); err != nil {
+		logger.FromContext(ctx).Errorf("failed to record security event for user %d: %v", refresh.UserID, err)
+	}
 }
 
 // --- Основные методы ---
 
-func (s *AuthService) CreateUser(user domain.User) (int, error) {
-	user.Password = generatePasswordHash(user.Password)
+func (s *AuthService) CreateUser(ctx context.Context, user domain.User) (int, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.CreateUser")
+	defer span.End()
+
+	hash, err := hashPassword(user.Password)
+	if err != nil {
+		return 0, domain.NewInternalServerError(err)
+	}
+	user.Password = hash
 
-	id, err := s.repo.CreateUser(user)
+	id, err := s.repo.CreateUser(ctx, user)
 	if err != nil {
 		// Проверяем ошибку на нарушение уникальности (Unique Violation) в Postgres
 		var pqErr *pq.Error
@@ -85,49 +206,81 @@ func (s *AuthService) CreateUser(user domain.User) (int, error) {
 	}
 
 	userName := strings.Split(user.Email, "@")[0]
-	if err := s.settingsService.CreateInitialUserSettings(id, userName); err != nil {
+	if err := s.settingsService.CreateInitialUserSettings(ctx, id, userName, ""); err != nil {
 		return 0, domain.NewInternalServerError(err)
 	}
 
 	return id, nil
 }
 
-func (s *AuthService) GenerateTokens(email, password string) (domain.ResponseTokens, error) {
-	userId, err := s.repo.GetUser(email, generatePasswordHash(password))
+// CreateGuest создает гостевой аккаунт и сразу выдает токены, чтобы играть без регистрации.
+// Прогресс сохраняется, пока гость не потеряет refresh токен или не превратит аккаунт в полноценный.
+func (s *AuthService) CreateGuest(ctx context.Context, device domain.DeviceInfo) (domain.ResponseTokens, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.CreateGuest")
+	defer span.End()
+
+	id, err := s.repo.CreateGuestUser(ctx)
 	if err != nil {
-		// Если пользователь не найден в БД, возвращаем типизированную ошибку
-		return domain.ResponseTokens{}, domain.ErrInvalidCredentials
+		return domain.ResponseTokens{}, domain.NewInternalServerError(err)
 	}
 
-	return s.createTokens(userId)
+	if err := s.settingsService.CreateInitialUserSettings(ctx, id, guestName, ""); err != nil {
+		return domain.ResponseTokens{}, domain.NewInternalServerError(err)
+	}
+
+	logger.FromContext(ctx).Infof("guest account %d created", id)
+	return s.createTokens(ctx, id, device)
 }
 
-func (s *AuthService) GenerateTokensForUser(userId int) (domain.ResponseTokens, error) {
-	return s.createTokens(userId)
+// GenerateTokens — вход по email и паролю. Если включен TOTP, вместо токенов возвращается MFA challenge.
+func (s *AuthService) GenerateTokens(ctx context.Context, email, password string, device domain.DeviceInfo) (domain.SignInResult, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.GenerateTokens")
+	defer span.End()
+
+	if err := s.checkLoginLock(ctx, email); err != nil {
+		return domain.SignInResult{}, err
+	}
+
+	user, err := s.checkCredentials(ctx, email, password)
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidCredentials) {
+			s.registerLoginFailure(ctx, email, device)
+		}
+		return domain.SignInResult{}, err
+	}
+	s.resetLoginFailures(ctx, email)
+
+	return s.signIn(ctx, user.ID, device)
 }
 
-func (s *AuthService) createTokens(userId int) (domain.ResponseTokens, error) {
-	// 1. Создаем Access Token (JWT)
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &tokenClaims{
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(accessTokenTTL)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
-		UserId: userId,
-	})
+// GenerateTokensForUser — вход пользователя, уже подтвердившего личность другим способом (OAuth).
+func (s *AuthService) GenerateTokensForUser(ctx context.Context, userId int, device domain.DeviceInfo) (domain.SignInResult, error) {
+	return s.signIn(ctx, userId, device)
+}
+
+func (s *AuthService) createTokens(ctx context.Context, userId int, device domain.DeviceInfo) (domain.ResponseTokens, error) {
+	// Любой успешный вход (пароль, OAuth, ключ доступа) восстанавливает аккаунт, ожидающий удаления
+	if cancelled, err := s.accountRepo.CancelAccountDeletion(ctx, userId); err != nil {
+		return domain.ResponseTokens{}, domain.NewInternalServerError(err)
+	} else if cancelled {
+		logger.FromContext(ctx).Infof("account deletion cancelled by sign-in of user %d", userId)
+	}
 
-	accessToken, err := token.SignedString([]byte(signingKey))
+	familyId := uuid.NewString()
+
+	// 1. Создаем Access Token (JWT)
+	accessToken, err := s.newAccessToken(ctx, userId, familyId)
 	if err != nil {
 		return domain.ResponseTokens{}, domain.NewInternalServerError(err)
 	}
 
-	// 2. Создаем Refresh Token
-	refresh, err := s.newRefreshToken(userId)
+	// 2. Создаем Refresh Token (новое семейство)
+	refresh, err := s.newRefreshToken(userId, familyId, device)
 	if err != nil {
 		return domain.ResponseTokens{}, err
 	}
 
-	if err = s.repo.CreateToken(refresh); err != nil {
+	if err = s.repo.CreateToken(ctx, refresh); err != nil {
 		return domain.ResponseTokens{}, domain.NewInternalServerError(err)
 	}
 
@@ -137,83 +290,184 @@ func (s *AuthService) createTokens(userId int) (domain.ResponseTokens, error) {
 	}, nil
 }
 
-func (s *AuthService) GetAccessToken(refreshToken string) (domain.ResponseTokens, error) {
-	refresh, err := s.repo.GetRefreshToken(refreshToken)
+func (s *AuthService) GetAccessToken(ctx context.Context, refreshToken string, device domain.DeviceInfo) (domain.ResponseTokens, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.GetAccessToken")
+	defer span.End()
+
+	refresh, err := s.repo.GetRefreshToken(ctx, refreshToken)
 	if err != nil {
 		return domain.ResponseTokens{}, domain.ErrInvalidToken
 	}
 
+	// Токен уже был обменян на новый — повторное использование
+	if refresh.RotatedAt != nil {
+		return s.rotatedTokenSuccessor(ctx, refresh)
+	}
+
 	if time.Now().After(refresh.ExpiresAt) {
-		_ = s.repo.DeleteRefreshToken(refresh.ID)
+		_ = s.repo.DeleteRefreshTokenFamily(ctx, refresh.FamilyID)
 		return domain.ResponseTokens{}, domain.ErrInvalidToken
 	}
 
 	// Создаем новый Access Token
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, &tokenClaims{
-		jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(accessTokenTTL)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
-		refresh.UserID,
-	})
-
-	accessToken, err := token.SignedString([]byte(signingKey))
+	accessToken, err := s.newAccessToken(ctx, refresh.UserID, refresh.FamilyID)
 	if err != nil {
 		return domain.ResponseTokens{}, domain.NewInternalServerError(err)
 	}
 
-	// Если Refresh Token скоро истечет, обновляем и его (Rotating Refresh Tokens)
-	currentRefreshToken := refresh.Token
-	if refresh.ExpiresAt.Before(time.Now().Add(updateRefreshTokenTTL)) {
-		newRefresh, err := s.newRefreshToken(refresh.UserID)
-		if err != nil {
-			return domain.ResponseTokens{}, err
-		}
+	// Refresh Token одноразовый: каждый обмен выдает новый токен того же семейства (Rotating Refresh Tokens)
+	newRefresh, err := s.newRefreshToken(refresh.UserID, refresh.FamilyID, device)
+	if err != nil {
+		return domain.ResponseTokens{}, err
+	}
+	newRefresh.NameDevice = refresh.NameDevice
 
-		if err := s.repo.UpdateToken(refreshToken, newRefresh); err != nil {
-			return domain.ResponseTokens{}, err
+	if err := s.repo.RotateToken(ctx, refresh.ID, newRefresh); err != nil {
+		// Параллельный запрос успел ротировать этот же токен
+		if errors.Is(err, sql.ErrNoRows) {
+			return s.rotatedTokenSuccessor(ctx, refresh)
 		}
-		currentRefreshToken = newRefresh.Token
+		return domain.ResponseTokens{}, domain.NewInternalServerError(err)
 	}
 
 	return domain.ResponseTokens{
 		AccessToken:  accessToken,
-		RefreshToken: currentRefreshToken,
+		RefreshToken: newRefresh.Token,
 	}, nil
 }
 
-func (s *AuthService) ParseToken(accessToken string) (int, error) {
-	token, err := jwt.ParseWithClaims(accessToken, &tokenClaims{}, func(token *jwt.Token) (interface{}, error) {
-		if _, ok := token.Method.(*jwt.SigningMethodHMAC); !ok {
-			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+// rotatedTokenSuccessor обрабатывает предъявление уже ротированного токена. Если ротация была
+// только что, а выданный вместо него токен еще не использован, это параллельный обмен
+// (две вкладки), и клиент получает тот же новый токен. Иначе токен украден — семейство отзывается.
+func (s *AuthService) rotatedTokenSuccessor(ctx context.Context, refresh domain.RefreshToken) (domain.ResponseTokens, error) {
+	successor, err := s.repo.GetRefreshTokenSuccessor(ctx, refresh.ID, refreshReuseGracePeriod)
+	if err != nil {
+		if !errors.Is(err, sql.ErrNoRows) {
+			return domain.ResponseTokens{}, domain.NewInternalServerError(err)
 		}
-		return []byte(signingKey), nil
-	})
+		s.revokeReusedFamily(ctx, refresh)
+		return domain.ResponseTokens{}, domain.ErrInvalidToken
+	}
 
+	accessToken, err := s.newAccessToken(ctx, successor.UserID, successor.FamilyID)
 	if err != nil {
-		return 0, domain.ErrInvalidToken
+		return domain.ResponseTokens{}, domain.NewInternalServerError(err)
 	}
 
-	claims, ok := token.Claims.(*tokenClaims)
-	if !ok || !token.Valid {
-		return 0, domain.ErrInvalidToken
-	}
+	return domain.ResponseTokens{
+		AccessToken:  accessToken,
+		RefreshToken: successor.Token,
+	}, nil
+}
+
+// startRotatedTokensCleanup — фоновый процесс удаления ротированных refresh токенов.
+// Ротированный токен нужен только чтобы распознать повторное использование, пока семейство
+// живо, поэтому строки старше RefreshTokenTTL удаляются.
+func (s *AuthService) startRotatedTokensCleanup() {
+	ticker := time.NewTicker(pruneRotatedTokensInterval)
+	defer ticker.Stop()
+
+	logrus.Infof("Фоновая задача: удаление ротированных refresh токенов каждые %v", pruneRotatedTokensInterval)
 
-	return claims.UserId, nil
+	for range ticker.C {
+		start := time.Now()
+		metrics.ObserveJob(metrics.JobRefreshTokenCleanup, start, s.pruneRotatedTokens(context.Background()))
+	}
 }
 
-func (s *AuthService) UnAuthorize(refreshToken string) error {
-	refresh, err := s.repo.GetRefreshToken(refreshToken)
+// pruneRotatedTokens — один запуск startRotatedTokensCleanup
+func (s *AuthService) pruneRotatedTokens(ctx context.Context) error {
+	deleted, err := s.repo.DeleteRotatedRefreshTokens(ctx, RefreshTokenTTL)
 	if err != nil {
+		logrus.Errorf("Ошибка при удалении ротированных refresh токенов: %v", err)
 		return err
 	}
-	return s.repo.DeleteRefreshToken(refresh.ID)
+	if deleted > 0 {
+		logrus.Infof("Удалено %d ротированных refresh токенов", deleted)
+	}
+	return nil
+}
+
+func (s *AuthService) ParseToken(ctx context.Context, accessToken string) (domain.AccessTokenClaims, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.ParseToken")
+	defer span.End()
+
+	token, err := s.keys.parse(accessToken, &tokenClaims{})
+	if err != nil {
+		return domain.AccessTokenClaims{}, domain.ErrInvalidToken
+	}
+
+	claims, ok := token.Claims.(*tokenClaims)
+	if !ok || !token.Valid || claims.ExpiresAt == nil || claims.IssuedAt == nil {
+		return domain.AccessTokenClaims{}, domain.ErrInvalidToken
+	}
+
+	result := domain.AccessTokenClaims{
+		UserID:    claims.UserId,
+		SessionID: claims.SessionId,
+		TokenID:   claims.ID,
+		Roles:     claims.Roles,
+		IssuedAt:  claims.IssuedAt.Time,
+		ExpiresAt: claims.ExpiresAt.Time,
+	}
+
+	// Токен мог быть отозван через logout до истечения срока
+	revoked, err := s.isAccessRevoked(ctx, result)
+	if err != nil {
+		return domain.AccessTokenClaims{}, domain.NewInternalServerError(err)
+	}
+	if revoked {
+		return domain.AccessTokenClaims{}, domain.ErrInvalidToken
+	}
+
+	return result, nil
+}
+
+// GetJWKS возвращает публичные ключи, которыми можно проверить access токены.
+func (s *AuthService) GetJWKS() domain.JSONWebKeySet {
+	return s.keys.JWKS()
 }
 
-func (s *AuthService) UnAuthorizeAll(email, password string) error {
-	id, err := s.repo.GetUser(email, generatePasswordHash(password))
+// UnAuthorize завершает текущую сессию: удаляет её refresh токены и сразу отзывает access токены.
+func (s *AuthService) UnAuthorize(ctx context.Context, claims domain.AccessTokenClaims) error {
+	ctx, span := tracer.Start(ctx, "AuthService.UnAuthorize")
+	defer span.End()
+
+	if claims.SessionID != "" {
+		if err := s.repo.DeleteRefreshTokenFamily(ctx, claims.SessionID); err != nil {
+			return domain.NewInternalServerError(err)
+		}
+		if err := s.revokeSessionAccess(ctx, claims.SessionID); err != nil {
+			return domain.NewInternalServerError(err)
+		}
+	}
+
+	if err := s.revokeAccessToken(ctx, claims); err != nil {
+		return domain.NewInternalServerError(err)
+	}
+	return nil
+}
+
+// UnAuthorizeAll завершает все сессии пользователя на всех устройствах.
+func (s *AuthService) UnAuthorizeAll(ctx context.Context, userId int) error {
+	ctx, span := tracer.Start(ctx, "AuthService.UnAuthorizeAll")
+	defer span.End()
+
+	tokens, err := s.repo.GetRefreshTokens(ctx, userId)
 	if err != nil {
-		return err
+		return domain.NewInternalServerError(err)
+	}
+
+	if err := s.repo.DeleteAllUserRefreshTokens(ctx, userId); err != nil {
+		return domain.NewInternalServerError(err)
+	}
+
+	sessionIds := make([]string, 0, len(tokens))
+	for _, token := range tokens {
+		sessionIds = append(sessionIds, token.FamilyID)
+	}
+	if err := s.revokeSessionsAccess(ctx, sessionIds); err != nil {
+		return domain.NewInternalServerError(err)
 	}
-	return s.repo.DeleteAllUserRefreshTokens(id)
+	return nil
 }
@@ -0,0 +1,116 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+const (
+	// emailVerificationTTL — срок жизни ссылки подтверждения
+	emailVerificationTTL = 24 * time.Hour
+
+	// verificationEmailCooldown — не чаще одного письма в минуту на пользователя
+	verificationEmailCooldown = time.Minute
+)
+
+type EmailVerificationService struct {
+	repo      domain.AuthorizationRepository
+	mailer    domain.Mailer
+	tokens    *actionTokens
+	redis     *redis.Client
+	verifyURL string
+}
+
+func NewEmailVerificationService(repo domain.AuthorizationRepository, mailer domain.Mailer, tokens *actionTokens, redis *redis.Client) *EmailVerificationService {
+	return &EmailVerificationService{
+		repo:      repo,
+		mailer:    mailer,
+		tokens:    tokens,
+		redis:     redis,
+		verifyURL: viper.GetString("mail.verifyEmailURL"),
+	}
+}
+
+// SendVerificationEmail отправляет (или повторно отправляет) письмо со ссылкой подтверждения.
+// Новое письмо делает ссылку из предыдущего недействительной.
+func (s *EmailVerificationService) SendVerificationEmail(ctx context.Context, userId int) error {
+	ctx, span := tracer.Start(ctx, "EmailVerificationService.SendVerificationEmail")
+	defer span.End()
+
+	user, err := s.repo.GetUserById(ctx, userId)
+	if err != nil {
+		return domain.ErrUserNotFound
+	}
+	if user.EmailVerified {
+		return domain.ErrEmailAlreadyVerified
+	}
+	if user.Email == "" {
+		// Аккаунт создан через провайдера без подтвержденной почты
+		return domain.NewInvalidRequestError(errors.New("account has no email"))
+	}
+
+	key := "verify_email_cooldown:" + strconv.Itoa(userId)
+	allowed, err := s.redis.SetNX(ctx, key, 1, verificationEmailCooldown).Result()
+	if err != nil {
+		return domain.NewInternalServerError(err)
+	}
+	if !allowed {
+		return domain.ErrTooManyVerificationEmails
+	}
+
+	token, err := s.tokens.Issue(ctx, actionEmailVerification, userId, emailVerificationTTL)
+	if err != nil {
+		return domain.NewInternalServerError(err)
+	}
+
+	link := s.verifyURL + "?token=" + url.QueryEscape(token)
+	msg := domain.MailMessage{
+		To:      user.Email,
+		Subject: "Подтверждение email в SeeThisGame",
+		Text: fmt.Sprintf("Чтобы подтвердить адрес, перейдите по ссылке:\n\n%s\n\n"+
+			"Ссылка действительна %d часа. Если вы не регистрировались в SeeThisGame, просто проигнорируйте это письмо.\n",
+			link, int(emailVerificationTTL.Hours())),
+	}
+	if err := s.mailer.Send(msg); err != nil {
+		// Даем отправить письмо повторно сразу, раз это не удалось
+		s.redis.Del(ctx, key)
+		return domain.NewInternalServerError(err)
+	}
+
+	return nil
+}
+
+// ConfirmEmail погашает токен из письма и отмечает email подтвержденным.
+func (s *EmailVerificationService) ConfirmEmail(ctx context.Context, token string) error {
+	ctx, span := tracer.Start(ctx, "EmailVerificationService.ConfirmEmail")
+	defer span.End()
+
+	userId, err := s.tokens.Consume(ctx, actionEmailVerification, token)
+	if err != nil {
+		return err
+	}
+
+	if err := s.repo.SetEmailVerified(ctx, userId); err != nil {
+		return domain.NewInternalServerError(err)
+	}
+	return nil
+}
+
+func (s *EmailVerificationService) IsEmailVerified(ctx context.Context, userId int) (bool, error) {
+	ctx, span := tracer.Start(ctx, "EmailVerificationService.IsEmailVerified")
+	defer span.End()
+
+	user, err := s.repo.GetUserById(ctx, userId)
+	if err != nil {
+		return false, domain.ErrUserNotFound
+	}
+	return user.EmailVerified, nil
+}
@@ -0,0 +1,120 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/ArtemChadaev/SeeThisGame/internal/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+// healthCheckTimeout — сколько ждать ответа каждой зависимости
+const healthCheckTimeout = 2 * time.Second
+
+type HealthService struct {
+	repo         domain.HealthRepository
+	redis        *redis.Client
+	shuttingDown atomic.Bool
+}
+
+func NewHealthService(repo domain.HealthRepository, redis *redis.Client) *HealthService {
+	return &HealthService{repo: repo, redis: redis}
+}
+
+func (s *HealthService) BeginShutdown() {
+	s.shuttingDown.Store(true)
+}
+
+// Readiness проверяет зависимости параллельно. Сервис готов, только если все проверки прошли.
+func (s *HealthService) Readiness(ctx context.Context) domain.Readiness {
+	checks := map[string]func(context.Context) domain.DependencyStatus{
+		"postgres":   s.checkPostgres,
+		"redis":      s.checkRedis,
+		"migrations": s.checkMigrations,
+	}
+
+	readiness := domain.Readiness{
+		Status: domain.HealthStatusOK,
+		Checks: make(map[string]domain.DependencyStatus, len(checks)+1),
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Go(func() {
+			checkCtx, cancel := context.WithTimeout(ctx, healthCheckTimeout)
+			defer cancel()
+
+			status := check(checkCtx)
+			mu.Lock()
+			readiness.Checks[name] = status
+			mu.Unlock()
+		})
+	}
+	wg.Wait()
+
+	shutdown := domain.DependencyStatus{Status: domain.HealthStatusOK}
+	if s.shuttingDown.Load() {
+		shutdown = domain.DependencyStatus{Status: domain.HealthStatusFail, Error: "shutting down"}
+	}
+	readiness.Checks["shutdown"] = shutdown
+
+	for _, status := range readiness.Checks {
+		if status.Status != domain.HealthStatusOK {
+			readiness.Status = domain.HealthStatusFail
+		}
+	}
+	return readiness
+}
+
+func (s *HealthService) checkPostgres(ctx context.Context) domain.DependencyStatus {
+	start := time.Now()
+	return dependencyStatus(ctx, "postgres", start, s.repo.Ping(ctx))
+}
+
+func (s *HealthService) checkRedis(ctx context.Context) domain.DependencyStatus {
+	start := time.Now()
+	return dependencyStatus(ctx, "redis", start, s.redis.Ping(ctx).Err())
+}
+
+// checkMigrations не пускает трафик на экземпляр, чья схема не совпадает со встроенными
+// миграциями или осталась в состоянии dirty после неудачного запуска
+func (s *HealthService) checkMigrations(ctx context.Context) domain.DependencyStatus {
+	start := time.Now()
+	version, err := s.repo.MigrationVersion(ctx)
+	if err != nil {
+		return dependencyStatus(ctx, "migrations", start, err)
+	}
+
+	status := dependencyStatus(ctx, "migrations", start, nil)
+	status.Version = &version.Current
+	status.Expected = &version.Expected
+	switch {
+	case version.Dirty:
+		status.Status = domain.HealthStatusFail
+		status.Error = fmt.Sprintf("migration %d is dirty", version.Current)
+	case version.Current != version.Expected:
+		status.Status = domain.HealthStatusFail
+		status.Error = "schema version does not match"
+	}
+	return status
+}
+
+// dependencyStatus — результат проверки. Текст ошибки (адреса, имена хостов) пишется
+// только в лог: /readyz доступен без авторизации.
+func dependencyStatus(ctx context.Context, name string, start time.Time, err error) domain.DependencyStatus {
+	status := domain.DependencyStatus{
+		Status:    domain.HealthStatusOK,
+		LatencyMs: time.Since(start).Milliseconds(),
+	}
+	if err != nil {
+		logger.FromContext(ctx).Warnf("readiness check %s failed: %v", name, err)
+		status.Status = domain.HealthStatusFail
+		status.Error = "unavailable"
+	}
+	return status
+}
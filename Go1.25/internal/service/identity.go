@@ -0,0 +1,97 @@
+package service
+
+import (
+	"context"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/ArtemChadaev/SeeThisGame/internal/logger"
+)
+
+type IdentityService struct {
+	repo     domain.IdentityRepository
+	authRepo domain.AuthorizationRepository
+	passkeys domain.PasskeyRepository
+}
+
+func NewIdentityService(repo domain.IdentityRepository, authRepo domain.AuthorizationRepository, passkeys domain.PasskeyRepository) *IdentityService {
+	return &IdentityService{
+		repo:     repo,
+		authRepo: authRepo,
+		passkeys: passkeys,
+	}
+}
+
+// GetIdentities возвращает способы входа пользователя. Пароль идет первым, если он задан.
+func (s *IdentityService) GetIdentities(ctx context.Context, userId int) ([]domain.Identity, error) {
+	ctx, span := tracer.Start(ctx, "IdentityService.GetIdentities")
+	defer span.End()
+
+	user, err := s.authRepo.GetUserById(ctx, userId)
+	if err != nil {
+		return nil, domain.ErrUserNotFound
+	}
+
+	identities, err := s.repo.GetIdentities(ctx, userId)
+	if err != nil {
+		return nil, domain.NewInternalServerError(err)
+	}
+
+	result := make([]domain.Identity, 0, len(identities)+1)
+	if user.Password != "" {
+		password := domain.Identity{UserID: userId, Provider: domain.IdentityPassword}
+		if user.Email != "" {
+			password.Email = &user.Email
+		}
+		result = append(result, password)
+	}
+
+	return append(result, identities...), nil
+}
+
+// UnlinkIdentity отвязывает провайдера, если у аккаунта останется другой способ входа:
+// пароль, другой провайдер или ключ доступа.
+func (s *IdentityService) UnlinkIdentity(ctx context.Context, userId int, provider string) error {
+	ctx, span := tracer.Start(ctx, "IdentityService.UnlinkIdentity")
+	defer span.End()
+
+	if provider == domain.IdentityPassword {
+		return domain.ErrIdentityNotFound
+	}
+
+	identities, err := s.GetIdentities(ctx, userId)
+	if err != nil {
+		return err
+	}
+
+	linked := false
+	for _, identity := range identities {
+		if identity.Provider == provider {
+			linked = true
+			break
+		}
+	}
+	if !linked {
+		return domain.ErrIdentityNotFound
+	}
+
+	if len(identities) == 1 {
+		passkeys, err := s.passkeys.GetPasskeys(ctx, userId)
+		if err != nil {
+			return domain.NewInternalServerError(err)
+		}
+		if len(passkeys) == 0 {
+			return domain.ErrLastIdentity
+		}
+	}
+
+	deleted, err := s.repo.DeleteIdentity(ctx, userId, provider)
+	if err != nil {
+		return domain.NewInternalServerError(err)
+	}
+	if !deleted {
+		return domain.ErrIdentityNotFound
+	}
+
+	logger.FromContext(ctx).Infof("%s identity unlinked by user %d", provider, userId)
+	return nil
+}
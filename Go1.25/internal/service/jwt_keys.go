@@ -0,0 +1,238 @@
+package service
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"sort"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/sirupsen/logrus"
+)
+
+// JWTKeyConfig описывает один ключ подписи из config.yml (секция jwt.keys).
+// Для проверки достаточно публичного ключа, для подписи нужен приватный (или секрет для HS256).
+type JWTKeyConfig struct {
+	Kid            string `mapstructure:"kid"`
+	Alg            string `mapstructure:"alg"`
+	PrivateKeyFile string `mapstructure:"privateKeyFile"`
+	PublicKeyFile  string `mapstructure:"publicKeyFile"`
+	// SecretEnv — имя переменной окружения с секретом для HS256
+	SecretEnv string `mapstructure:"secretEnv"`
+}
+
+type JWTConfig struct {
+	Issuer string `mapstructure:"issuer"`
+	// ActiveKid — ключ, которым подписываются новые токены. Остальные только проверяют старые.
+	ActiveKid string         `mapstructure:"activeKid"`
+	Keys      []JWTKeyConfig `mapstructure:"keys"`
+	// AllowEphemeralKey — только для локальной разработки: без ключей генерировать временный.
+	// Иначе пустой keys — ошибка запуска, чтобы прод не остался с ключом, который меняется при каждом рестарте.
+	AllowEphemeralKey bool `mapstructure:"allowEphemeralKey"`
+}
+
+type jwtKey struct {
+	kid       string
+	method    jwt.SigningMethod
+	signKey   interface{} // nil, если ключ только для проверки
+	verifyKey interface{}
+}
+
+// JWTKeySet хранит ключи подписи access токенов и поддерживает ротацию:
+// подписывает активным ключом, а проверяет любым из загруженных по заголовку kid.
+type JWTKeySet struct {
+	issuer string
+	active *jwtKey
+	keys   map[string]*jwtKey
+}
+
+func NewJWTKeySet(cfg JWTConfig) (*JWTKeySet, error) {
+	set := &JWTKeySet{
+		issuer: cfg.Issuer,
+		keys:   make(map[string]*jwtKey),
+	}
+
+	if len(cfg.Keys) == 0 {
+		if !cfg.AllowEphemeralKey {
+			return nil, errors.New("jwt.keys is empty: configure a signing key or set jwt.allowEphemeralKey for local development")
+		}
+		// Для локального запуска без ключей генерируем временный — после рестарта все access токены станут невалидны
+		logrus.Warn("jwt.keys is empty, using ephemeral Ed25519 signing key")
+		_, private, err := ed25519.GenerateKey(rand.Reader)
+		if err != nil {
+			return nil, err
+		}
+		key := &jwtKey{
+			kid:       "ephemeral",
+			method:    jwt.SigningMethodEdDSA,
+			signKey:   private,
+			verifyKey: private.Public(),
+		}
+		set.keys[key.kid] = key
+		set.active = key
+		return set, nil
+	}
+
+	for _, keyCfg := range cfg.Keys {
+		key, err := loadJWTKey(keyCfg)
+		if err != nil {
+			return nil, fmt.Errorf("jwt key %q: %w", keyCfg.Kid, err)
+		}
+		if _, exists := set.keys[key.kid]; exists {
+			return nil, fmt.Errorf("jwt key %q: duplicate kid", key.kid)
+		}
+		set.keys[key.kid] = key
+	}
+
+	active, ok := set.keys[cfg.ActiveKid]
+	if !ok {
+		return nil, fmt.Errorf("jwt active key %q not found", cfg.ActiveKid)
+	}
+	if active.signKey == nil {
+		return nil, fmt.Errorf("jwt active key %q has no private key", cfg.ActiveKid)
+	}
+	set.active = active
+
+	return set, nil
+}
+
+func loadJWTKey(cfg JWTKeyConfig) (*jwtKey, error) {
+	if cfg.Kid == "" {
+		return nil, errors.New("kid is required")
+	}
+	key := &jwtKey{kid: cfg.Kid}
+
+	switch cfg.Alg {
+	case "HS256":
+		secret := os.Getenv(cfg.SecretEnv)
+		if cfg.SecretEnv == "" || secret == "" {
+			return nil, errors.New("secretEnv is not set")
+		}
+		key.method = jwt.SigningMethodHS256
+		key.signKey = []byte(secret)
+		key.verifyKey = []byte(secret)
+
+	case "RS256":
+		key.method = jwt.SigningMethodRS256
+		if cfg.PrivateKeyFile != "" {
+			pem, err := os.ReadFile(cfg.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			private, err := jwt.ParseRSAPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, err
+			}
+			key.signKey = private
+			key.verifyKey = &private.PublicKey
+		} else {
+			pem, err := os.ReadFile(cfg.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			public, err := jwt.ParseRSAPublicKeyFromPEM(pem)
+			if err != nil {
+				return nil, err
+			}
+			key.verifyKey = public
+		}
+
+	case "EdDSA":
+		key.method = jwt.SigningMethodEdDSA
+		if cfg.PrivateKeyFile != "" {
+			pem, err := os.ReadFile(cfg.PrivateKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			private, err := jwt.ParseEdPrivateKeyFromPEM(pem)
+			if err != nil {
+				return nil, err
+			}
+			key.signKey = private
+			key.verifyKey = private.(crypto.Signer).Public()
+		} else {
+			pem, err := os.ReadFile(cfg.PublicKeyFile)
+			if err != nil {
+				return nil, err
+			}
+			public, err := jwt.ParseEdPublicKeyFromPEM(pem)
+			if err != nil {
+				return nil, err
+			}
+			key.verifyKey = public
+		}
+
+	default:
+		return nil, fmt.Errorf("unsupported alg %q", cfg.Alg)
+	}
+
+	return key, nil
+}
+
+// sign подписывает claims активным ключом и проставляет kid в заголовок.
+func (k *JWTKeySet) sign(claims jwt.Claims) (string, error) {
+	token := jwt.NewWithClaims(k.active.method, claims)
+	token.Header["kid"] = k.active.kid
+	return token.SignedString(k.active.signKey)
+}
+
+// parse проверяет подпись ключом из заголовка kid и заполняет claims.
+func (k *JWTKeySet) parse(tokenString string, claims jwt.Claims) (*jwt.Token, error) {
+	options := []jwt.ParserOption{jwt.WithValidMethods([]string{"HS256", "RS256", "EdDSA"})}
+	if k.issuer != "" {
+		options = append(options, jwt.WithIssuer(k.issuer))
+	}
+
+	return jwt.ParseWithClaims(tokenString, claims, func(token *jwt.Token) (interface{}, error) {
+		kid, _ := token.Header["kid"].(string)
+		key, ok := k.keys[kid]
+		if !ok {
+			return nil, fmt.Errorf("unknown kid: %q", kid)
+		}
+		// Алгоритм берем из ключа, а не из заголовка, иначе возможна подмена (alg confusion)
+		if token.Method.Alg() != key.method.Alg() {
+			return nil, fmt.Errorf("unexpected signing method: %v", token.Header["alg"])
+		}
+		return key.verifyKey, nil
+	}, options...)
+}
+
+// JWKS возвращает публичные ключи для проверки токенов другими сервисами.
+// Симметричные (HS256) ключи не публикуются.
+func (k *JWTKeySet) JWKS() domain.JSONWebKeySet {
+	set := domain.JSONWebKeySet{Keys: []domain.JSONWebKey{}}
+
+	for _, key := range k.keys {
+		switch public := key.verifyKey.(type) {
+		case ed25519.PublicKey:
+			set.Keys = append(set.Keys, domain.JSONWebKey{
+				Kty: "OKP",
+				Kid: key.kid,
+				Alg: key.method.Alg(),
+				Use: "sig",
+				Crv: "Ed25519",
+				X:   base64.RawURLEncoding.EncodeToString(public),
+			})
+		case *rsa.PublicKey:
+			set.Keys = append(set.Keys, domain.JSONWebKey{
+				Kty: "RSA",
+				Kid: key.kid,
+				Alg: key.method.Alg(),
+				Use: "sig",
+				N:   base64.RawURLEncoding.EncodeToString(public.N.Bytes()),
+				E:   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes()),
+			})
+		}
+	}
+
+	sort.Slice(set.Keys, func(i, j int) bool { return set.Keys[i].Kid < set.Keys[j].Kid })
+
+	return set
+}
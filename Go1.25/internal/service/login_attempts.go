@@ -0,0 +1,172 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/ArtemChadaev/SeeThisGame/internal/logger"
+)
+
+// Защита отдельного аккаунта от перебора пароля. authRateLimiter ограничивает IP,
+// а здесь неудачные попытки считаются по email, откуда бы они ни приходили.
+const (
+	// loginFreeAttempts — сколько ошибок подряд допускается без блокировки
+	loginFreeAttempts = 5
+	// loginLockBase — первая блокировка; каждая следующая ошибка удваивает срок
+	loginLockBase = time.Minute
+	// loginLockMax — максимальный срок блокировки
+	loginLockMax = time.Hour
+	// loginFailuresTTL — счетчик сбрасывается, если ошибок не было это время
+	loginFailuresTTL = 24 * time.Hour
+
+	loginFailuresKey = "login_failures:" // + email
+	loginLockKey     = "login_lock:"     // + email
+
+	// Неверные коды второго фактора считаются по пользователю, а не по MFA challenge:
+	// новый вход по паролю выдает новый challenge, но не сбрасывает счетчик
+	mfaFailuresKey = "mfa_failures:" // + user id
+	mfaLockKey     = "mfa_lock:"     // + user id
+)
+
+func loginAttemptsID(email string) string {
+	return strings.ToLower(strings.TrimSpace(email))
+}
+
+// checkLoginLock возвращает ErrAccountLocked, если вход по email временно заблокирован.
+// Если Redis недоступен, не блокируем пользователя (как и rateLimiter).
+func (s *AuthService) checkLoginLock(ctx context.Context, email string) error {
+	ttl, err := s.redis.TTL(ctx, loginLockKey+loginAttemptsID(email)).Result()
+	if err != nil {
+		logger.FromContext(ctx).Errorf("failed to check sign-in lock: %v", err)
+		return nil
+	}
+	if ttl > 0 {
+		return domain.NewAccountLockedError(ttl)
+	}
+	return nil
+}
+
+// registerLoginFailure увеличивает счетчик ошибок. Начиная с loginFreeAttempts+1 ошибки
+// вход блокируется на loginLockBase, 2×loginLockBase, 4×… но не дольше loginLockMax.
+func (s *AuthService) registerLoginFailure(ctx context.Context, email string, device domain.DeviceInfo) {
+	id := loginAttemptsID(email)
+
+	pipe := s.redis.Pipeline()
+	incr := pipe.Incr(ctx, loginFailuresKey+id)
+	pipe.Expire(ctx, loginFailuresKey+id, loginFailuresTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		logger.FromContext(ctx).Errorf("failed to count sign-in failure: %v", err)
+		return
+	}
+
+	failures := incr.Val()
+	if failures <= loginFreeAttempts {
+		return
+	}
+
+	lock := failureLockDuration(failures)
+	if err := s.redis.Set(ctx, loginLockKey+id, 1, lock).Err(); err != nil {
+		logger.FromContext(ctx).Errorf("failed to lock sign-in: %v", err)
+		return
+	}
+
+	logger.FromContext(ctx).Warnf("sign-in locked for %s for %v after %d failed attempts, last from ip %s", logger.Redact(id), lock, failures, device.IP)
+
+	// В журнал пишем, только если аккаунт существует: перебор несуществующих адресов виден в логах
+	user, err := s.repo.GetUserByEmail(ctx, email)
+	if err != nil {
+		return
+	}
+	event := domain.SecurityEvent{
+		UserID: user.ID,
+		Type:   domain.SecurityEventSignInLocked,
+		Details: map[string]string{
+			"failures": strconv.FormatInt(failures, 10),
+			"lock":     lock.String(),
+			"ip":       device.IP,
+		},
+	}
+	if err := s.repo.CreateSecurityEvent(ctx, event); err != nil {
+		logger.FromContext(ctx).Errorf("failed to record security event for user %d: %v", user.ID, err)
+	}
+}
+
+// failureLockDuration — срок блокировки после failures ошибок подряд (failures > loginFreeAttempts)
+func failureLockDuration(failures int64) time.Duration {
+	if shift := failures - loginFreeAttempts - 1; shift < 6 {
+		return min(loginLockBase<<shift, loginLockMax)
+	}
+	return loginLockMax
+}
+
+// resetLoginFailures снимает счетчик и блокировку: после успешного входа или сброса пароля.
+func (s *AuthService) resetLoginFailures(ctx context.Context, email string) {
+	id := loginAttemptsID(email)
+	if err := s.redis.Del(ctx, loginFailuresKey+id, loginLockKey+id).Err(); err != nil {
+		logger.FromContext(ctx).Errorf("failed to reset sign-in failures: %v", err)
+	}
+}
+
+// checkMFALock возвращает ErrAccountLocked, если проверка второго фактора пользователя
+// временно заблокирована. Если Redis недоступен, не блокируем (как и checkLoginLock).
+func (s *MFAService) checkMFALock(ctx context.Context, userId int) error {
+	ttl, err := s.redis.TTL(ctx, mfaLockKey+strconv.Itoa(userId)).Result()
+	if err != nil {
+		logger.FromContext(ctx).Errorf("failed to check second factor lock: %v", err)
+		return nil
+	}
+	if ttl > 0 {
+		return domain.NewAccountLockedError(ttl)
+	}
+	return nil
+}
+
+// registerMFAFailure считает неверный код второго фактора и блокирует проверку
+// по тем же правилам, что и registerLoginFailure.
+func (s *MFAService) registerMFAFailure(ctx context.Context, userId int) {
+	id := strconv.Itoa(userId)
+
+	pipe := s.redis.Pipeline()
+	incr := pipe.Incr(ctx, mfaFailuresKey+id)
+	pipe.Expire(ctx, mfaFailuresKey+id, loginFailuresTTL)
+	if _, err := pipe.Exec(ctx); err != nil {
+		logger.FromContext(ctx).Errorf("failed to count second factor failure: %v", err)
+		return
+	}
+
+	failures := incr.Val()
+	if failures <= loginFreeAttempts {
+		return
+	}
+
+	lock := failureLockDuration(failures)
+	if err := s.redis.Set(ctx, mfaLockKey+id, 1, lock).Err(); err != nil {
+		logger.FromContext(ctx).Errorf("failed to lock second factor: %v", err)
+		return
+	}
+
+	logger.FromContext(ctx).Warnf("second factor locked for user %d for %v after %d failed codes", userId, lock, failures)
+
+	event := domain.SecurityEvent{
+		UserID: userId,
+		Type:   domain.SecurityEventMFALocked,
+		Details: map[string]string{
+			"failures": strconv.FormatInt(failures, 10),
+			"lock":     lock.String(),
+		},
+	}
+	if err := s.authRepo.CreateSecurityEvent(ctx, event); err != nil {
+		logger.FromContext(ctx).Errorf("failed to record security event for user %d: %v", userId, err)
+	}
+}
+
+// resetMFAFailures снимает счетчик и блокировку второго фактора после верного кода
+func (s *MFAService) resetMFAFailures(ctx context.Context, userId int) {
+	id := strconv.Itoa(userId)
+	if err := s.redis.Del(ctx, mfaFailuresKey+id, mfaLockKey+id).Err(); err != nil {
+		logger.FromContext(ctx).Errorf("failed to reset second factor failures: %v", err)
+	}
+}
@@ -0,0 +1,324 @@
+package service
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"database/sql"
+	"encoding/base32"
+	"encoding/hex"
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/ArtemChadaev/SeeThisGame/internal/logger"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// mfaChallengeTTL — время на ввод кода после пароля
+	mfaChallengeTTL = 5 * time.Minute
+	// mfaChallengeMaxAttempts — после стольких неверных кодов challenge сгорает
+	mfaChallengeMaxAttempts = 5
+
+	recoveryCodesCount = 10
+
+	mfaChallengeKey         = "mfa_challenge:"
+	mfaChallengeAttemptsKey = "mfa_challenge_attempts:"
+)
+
+// signIn завершает первичную проверку (пароль, OAuth): выдает токены
+// или, если у пользователя включен TOTP, MFA challenge.
+func (s *AuthService) signIn(ctx context.Context, userId int, device domain.DeviceInfo) (domain.SignInResult, error) {
+	totp, err := s.mfaRepo.GetTOTP(ctx, userId)
+	if err != nil && !errors.Is(err, sql.ErrNoRows) {
+		return domain.SignInResult{}, domain.NewInternalServerError(err)
+	}
+
+	if err == nil && totp.Enabled {
+		challenge, err := s.newMFAChallenge(ctx, userId)
+		if err != nil {
+			return domain.SignInResult{}, domain.NewInternalServerError(err)
+		}
+		return domain.SignInResult{Challenge: &challenge}, nil
+	}
+
+	tokens, err := s.createTokens(ctx, userId, device)
+	if err != nil {
+		return domain.SignInResult{}, err
+	}
+	return domain.SignInResult{Tokens: &tokens}, nil
+}
+
+func (s *AuthService) newMFAChallenge(ctx context.Context, userId int) (domain.MFAChallenge, error) {
+	token, err := generateRefreshToken()
+	if err != nil {
+		return domain.MFAChallenge{}, err
+	}
+
+	if err := s.redis.Set(ctx, mfaChallengeKey+token, userId, mfaChallengeTTL).Err(); err != nil {
+		return domain.MFAChallenge{}, err
+	}
+
+	return domain.MFAChallenge{
+		MFARequired: true,
+		MFAToken:    token,
+		ExpiresIn:   int(mfaChallengeTTL.Seconds()),
+	}, nil
+}
+
+type MFAService struct {
+	repo        domain.MFARepository
+	authRepo    domain.AuthorizationRepository
+	authService *AuthService
+	redis       *redis.Client
+}
+
+func NewMFAService(repo domain.MFARepository, authRepo domain.AuthorizationRepository, authService *AuthService, redis *redis.Client) *MFAService {
+	return &MFAService{
+		repo:        repo,
+		authRepo:    authRepo,
+		authService: authService,
+		redis:       redis,
+	}
+}
+
+// EnrollTOTP создает новый (еще не активный) секрет. Включается он только после ConfirmTOTP.
+func (s *MFAService) EnrollTOTP(ctx context.Context, userId int) (domain.TOTPEnrollment, error) {
+	ctx, span := tracer.Start(ctx, "MFAService.EnrollTOTP")
+	defer span.End()
+
+	if totp, err := s.repo.GetTOTP(ctx, userId); err == nil && totp.Enabled {
+		return domain.TOTPEnrollment{}, domain.ErrMFAAlreadyEnabled
+	}
+
+	email, err := s.authRepo.GetUserEmailFromId(ctx, userId)
+	if err != nil {
+		return domain.TOTPEnrollment{}, domain.ErrUserNotFound
+	}
+	if email == "" {
+		email = "user-" + strconv.Itoa(userId)
+	}
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		return domain.TOTPEnrollment{}, domain.NewInternalServerError(err)
+	}
+
+	if err := s.repo.SaveTOTPSecret(ctx, userId, secret); err != nil {
+		return domain.TOTPEnrollment{}, domain.NewInternalServerError(err)
+	}
+
+	return domain.TOTPEnrollment{
+		Secret: secret,
+		URI:    totpURI(email, secret),
+	}, nil
+}
+
+// ConfirmTOTP включает TOTP после проверки первого кода и возвращает коды восстановления.
+// Коды показываются пользователю один раз, в базе остаются только хеши.
+func (s *MFAService) ConfirmTOTP(ctx context.Context, userId int, code string) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "MFAService.ConfirmTOTP")
+	defer span.End()
+
+	totp, err := s.repo.GetTOTP(ctx, userId)
+	if err != nil {
+		return nil, domain.ErrMFANotEnrolled
+	}
+	if totp.Enabled {
+		return nil, domain.ErrMFAAlreadyEnabled
+	}
+
+	if err := s.checkTOTP(ctx, totp, code); err != nil {
+		return nil, err
+	}
+
+	if err := s.repo.EnableTOTP(ctx, userId); err != nil {
+		return nil, domain.NewInternalServerError(err)
+	}
+
+	logger.FromContext(ctx).Infof("TOTP enabled for user %d", userId)
+	return s.newRecoveryCodes(ctx, userId)
+}
+
+// DisableTOTP отключает TOTP. Нужен действующий код или код восстановления.
+func (s *MFAService) DisableTOTP(ctx context.Context, userId int, code string) error {
+	ctx, span := tracer.Start(ctx, "MFAService.DisableTOTP")
+	defer span.End()
+
+	if err := s.verifySecondFactor(ctx, userId, code); err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteTOTP(ctx, userId); err != nil {
+		return domain.NewInternalServerError(err)
+	}
+	if err := s.repo.DeleteRecoveryCodes(ctx, userId); err != nil {
+		return domain.NewInternalServerError(err)
+	}
+
+	logger.FromContext(ctx).Infof("TOTP disabled for user %d", userId)
+	return nil
+}
+
+// RegenerateRecoveryCodes заменяет все коды восстановления новыми.
+func (s *MFAService) RegenerateRecoveryCodes(ctx context.Context, userId int, code string) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "MFAService.RegenerateRecoveryCodes")
+	defer span.End()
+
+	if err := s.verifySecondFactor(ctx, userId, code); err != nil {
+		return nil, err
+	}
+	return s.newRecoveryCodes(ctx, userId)
+}
+
+// CompleteSignIn — второй шаг входа: проверяет код для MFA challenge и выдает токены.
+func (s *MFAService) CompleteSignIn(ctx context.Context, mfaToken, code string, device domain.DeviceInfo) (domain.ResponseTokens, error) {
+	ctx, span := tracer.Start(ctx, "MFAService.CompleteSignIn")
+	defer span.End()
+
+	userId, err := s.redis.Get(ctx, mfaChallengeKey+mfaToken).Int()
+	if err != nil {
+		return domain.ResponseTokens{}, domain.ErrInvalidToken
+	}
+
+	if err := s.verifySecondFactor(ctx, userId, code); err != nil {
+		attemptsKey := mfaChallengeAttemptsKey + mfaToken
+		attempts, incrErr := s.redis.Incr(ctx, attemptsKey).Result()
+		if incrErr == nil {
+			s.redis.Expire(ctx, attemptsKey, mfaChallengeTTL)
+		}
+		if incrErr != nil || attempts >= mfaChallengeMaxAttempts {
+			s.redis.Del(ctx, mfaChallengeKey+mfaToken, attemptsKey)
+		}
+		return domain.ResponseTokens{}, err
+	}
+
+	// Challenge одноразовый: если параллельный запрос успел раньше — отказываем
+	deleted, err := s.redis.Del(ctx, mfaChallengeKey+mfaToken).Result()
+	if err != nil {
+		return domain.ResponseTokens{}, domain.NewInternalServerError(err)
+	}
+	if deleted == 0 {
+		return domain.ResponseTokens{}, domain.ErrInvalidToken
+	}
+	s.redis.Del(ctx, mfaChallengeAttemptsKey+mfaToken)
+
+	return s.authService.createTokens(ctx, userId, device)
+}
+
+// requireSecondFactor проверяет код, если у пользователя включен TOTP. Без TOTP код не нужен.
+func (s *MFAService) requireSecondFactor(ctx context.Context, userId int, code string) error {
+	totp, err := s.repo.GetTOTP(ctx, userId)
+	if errors.Is(err, sql.ErrNoRows) || (err == nil && !totp.Enabled) {
+		return nil
+	}
+	if err != nil {
+		return domain.NewInternalServerError(err)
+	}
+	if strings.TrimSpace(code) == "" {
+		return domain.ErrMFACodeRequired
+	}
+	return s.verifySecondFactor(ctx, userId, code)
+}
+
+// verifySecondFactor принимает TOTP код или код восстановления. Неверные коды считаются
+// по пользователю: после серии ошибок проверка блокируется, какой бы challenge ни предъявили.
+func (s *MFAService) verifySecondFactor(ctx context.Context, userId int, code string) error {
+	totp, err := s.repo.GetTOTP(ctx, userId)
+	if err != nil || !totp.Enabled {
+		return domain.ErrMFANotEnrolled
+	}
+
+	if err := s.checkMFALock(ctx, userId); err != nil {
+		return err
+	}
+
+	if err := s.checkSecondFactor(ctx, userId, totp, code); err != nil {
+		if errors.Is(err, domain.ErrInvalidMFACode) {
+			s.registerMFAFailure(ctx, userId)
+		}
+		return err
+	}
+
+	s.resetMFAFailures(ctx, userId)
+	return nil
+}
+
+func (s *MFAService) checkSecondFactor(ctx context.Context, userId int, totp domain.TOTP, code string) error {
+	if len(strings.TrimSpace(code)) == totpDigits {
+		return s.checkTOTP(ctx, totp, code)
+	}
+
+	used, err := s.repo.UseRecoveryCode(ctx, userId, hashRecoveryCode(code))
+	if err != nil {
+		return domain.NewInternalServerError(err)
+	}
+	if !used {
+		return domain.ErrInvalidMFACode
+	}
+
+	logger.FromContext(ctx).Infof("recovery code used by user %d", userId)
+	return nil
+}
+
+func (s *MFAService) checkTOTP(ctx context.Context, totp domain.TOTP, code string) error {
+	step, ok := validateTOTP(totp.Secret, code, time.Now())
+	if !ok {
+		return domain.ErrInvalidMFACode
+	}
+
+	// Один и тот же код (шаг) нельзя использовать повторно
+	fresh, err := s.repo.UpdateTOTPLastStep(ctx, totp.UserID, step)
+	if err != nil {
+		return domain.NewInternalServerError(err)
+	}
+	if !fresh {
+		return domain.ErrInvalidMFACode
+	}
+	return nil
+}
+
+func (s *MFAService) newRecoveryCodes(ctx context.Context, userId int) ([]string, error) {
+	codes := make([]string, 0, recoveryCodesCount)
+	hashes := make([]string, 0, recoveryCodesCount)
+
+	for i := 0; i < recoveryCodesCount; i++ {
+		code, err := generateRecoveryCode()
+		if err != nil {
+			return nil, domain.NewInternalServerError(err)
+		}
+		codes = append(codes, code)
+		hashes = append(hashes, hashRecoveryCode(code))
+	}
+
+	if err := s.repo.ReplaceRecoveryCodes(ctx, userId, hashes); err != nil {
+		return nil, domain.NewInternalServerError(err)
+	}
+	return codes, nil
+}
+
+// generateRecoveryCode возвращает 80-битный код вида XXXX-XXXX-XXXX-XXXX.
+func generateRecoveryCode() (string, error) {
+	raw := make([]byte, 10)
+	if _, err := rand.Read(raw); err != nil {
+		return "", err
+	}
+	encoded := base32.StdEncoding.EncodeToString(raw)
+
+	parts := make([]string, 0, 4)
+	for i := 0; i < len(encoded); i += 4 {
+		parts = append(parts, encoded[i:i+4])
+	}
+	return strings.Join(parts, "-"), nil
+}
+
+// hashRecoveryCode нормализует ввод (регистр, дефисы, пробелы) и хеширует.
+// Коды случайные и длинные, поэтому медленный хеш не нужен.
+func hashRecoveryCode(code string) string {
+	normalized := strings.ToUpper(strings.NewReplacer("-", "", " ", "").Replace(code))
+	sum := sha256.Sum256([]byte(normalized))
+	return hex.EncodeToString(sum[:])
+}
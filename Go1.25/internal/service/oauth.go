@@ -2,230 +2,272 @@ package service
 
 import (
 	"context"
+	"crypto/subtle"
+	"database/sql"
 	"encoding/json"
 	"errors"
-	"fmt"
-	"io"
-	"net/http"
+	"os"
+	"time"
 
 	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
-	"github.com/spf13/viper"
+	"github.com/ArtemChadaev/SeeThisGame/internal/logger"
+	"github.com/lib/pq"
+	"github.com/redis/go-redis/v9"
 	"golang.org/x/oauth2"
-	"golang.org/x/oauth2/github"
-	"golang.org/x/oauth2/google"
 )
 
-// oauthUserInfo — локальная структура для временного хранения данных из провайдеров
-type oauthUserInfo struct {
-	ID      string
-	Email   string
-	Name    string
-	Picture string
+const (
+	// OAuthStateTTL — сколько ждем возврата пользователя от провайдера
+	OAuthStateTTL = 10 * time.Minute
+
+	oauthStateKey = "oauth_state:"
+)
+
+// oauthAttempt — данные одной попытки входа, сохраненные в Redis по state
+type oauthAttempt struct {
+	Provider     string `json:"provider"`
+	CodeVerifier string `json:"codeVerifier"`
+	// Nonce связывает ID токен OIDC с этой попыткой
+	Nonce string `json:"nonce,omitempty"`
+	// LinkUserId — не 0, если попытка начата из настроек для привязки провайдера к аккаунту
+	LinkUserId int `json:"linkUserId,omitempty"`
 }
 
 type OAuthService struct {
-	repo         domain.AuthorizationRepository // Используем новый интерфейс из domain
-	authService  *AuthService
-	googleConfig *oauth2.Config
-	githubConfig *oauth2.Config
+	repo        domain.AuthorizationRepository // Используем новый интерфейс из domain
+	identities  domain.IdentityRepository
+	authService *AuthService
+	providers   *OAuthProviders
+	redis       *redis.Client
+
+	// telegramBotToken — токен бота для проверки Telegram Login Widget; пустой — вход отключен
+	telegramBotToken   string
+	telegramAuthMaxAge time.Duration
 }
 
-func NewOAuthService(repo domain.AuthorizationRepository, authService *AuthService) *OAuthService {
+func NewOAuthService(repo domain.AuthorizationRepository, identities domain.IdentityRepository, authService *AuthService, providers *OAuthProviders, redis *redis.Client) *OAuthService {
 	return &OAuthService{
 		repo:        repo,
+		identities:  identities,
 		authService: authService,
-		googleConfig: &oauth2.Config{
-			ClientID:     viper.GetString("oauth.google.clientID"),
-			ClientSecret: viper.GetString("oauth.google.clientSecret"),
-			RedirectURL:  viper.GetString("oauth.google.redirectURL"),
-			Scopes:       viper.GetStringSlice("oauth.google.scopes"),
-			Endpoint:     google.Endpoint,
-		},
-		githubConfig: &oauth2.Config{
-			ClientID:     viper.GetString("oauth.github.clientID"),
-			ClientSecret: viper.GetString("oauth.github.clientSecret"),
-			RedirectURL:  viper.GetString("oauth.github.redirectURL"),
-			Scopes:       viper.GetStringSlice("oauth.github.scopes"),
-			Endpoint:     github.Endpoint,
-		},
+		providers:   providers,
+		redis:       redis,
+
+		telegramBotToken:   os.Getenv("TELEGRAM_BOT_TOKEN"),
+		telegramAuthMaxAge: telegramAuthMaxAge(),
 	}
 }
 
-func (s *OAuthService) GetAuthURL(provider string) (string, error) {
-	var config *oauth2.Config
-	switch provider {
-	case "google":
-		config = s.googleConfig
-	case "github":
-		config = s.githubConfig
-	default:
-		return "", errors.New("unsupported provider")
-	}
+// GetAuthURL начинает вход через провайдера. Для каждой попытки генерируются
+// случайный state и PKCE verifier; state нужно сохранить в браузере (cookie),
+// чтобы callback можно было принять только в том же браузере.
+func (s *OAuthService) GetAuthURL(ctx context.Context, provider string) (string, string, error) {
+	ctx, span := tracer.Start(ctx, "OAuthService.GetAuthURL")
+	defer span.End()
 
-	state := "random-state-string" // В идеале генерировать динамически
-	return config.AuthCodeURL(state, oauth2.AccessTypeOffline), nil
+	return s.beginAttempt(ctx, provider, 0)
 }
 
-func (s *OAuthService) HandleCallback(provider, code string) (domain.ResponseTokens, error) {
-	var config *oauth2.Config
-	switch provider {
-	case "google":
-		config = s.googleConfig
-	case "github":
-		config = s.githubConfig
-	default:
-		return domain.ResponseTokens{}, errors.New("unsupported provider")
+// GetLinkURL начинает привязку провайдера к аккаунту userId.
+// Callback тот же, что и при входе, но вместо токенов он добавит способ входа.
+func (s *OAuthService) GetLinkURL(ctx context.Context, userId int, provider string) (string, string, error) {
+	ctx, span := tracer.Start(ctx, "OAuthService.GetLinkURL")
+	defer span.End()
+
+	return s.beginAttempt(ctx, provider, userId)
+}
+
+func (s *OAuthService) beginAttempt(ctx context.Context, providerName string, linkUserId int) (string, string, error) {
+	provider, err := s.providers.get(providerName)
+	if err != nil {
+		return "", "", err
+	}
+
+	state, err := generateRefreshToken()
+	if err != nil {
+		return "", "", domain.NewInternalServerError(err)
+	}
+	nonce, err := generateRefreshToken()
+	if err != nil {
+		return "", "", domain.NewInternalServerError(err)
+	}
+	attempt := oauthAttempt{
+		Provider:     providerName,
+		CodeVerifier: oauth2.GenerateVerifier(),
+		Nonce:        nonce,
+		LinkUserId:   linkUserId,
 	}
 
-	token, err := config.Exchange(context.Background(), code)
+	authURL, err := provider.authCodeURL(oauthContext(ctx), state, attempt.CodeVerifier, attempt.Nonce)
 	if err != nil {
-		return domain.ResponseTokens{}, err
+		return "", "", domain.NewOAuthProviderError(err)
 	}
 
-	userInfo, err := s.getUserInfo(provider, token)
+	data, err := json.Marshal(attempt)
 	if err != nil {
-		return domain.ResponseTokens{}, err
+		return "", "", domain.NewInternalServerError(err)
+	}
+	if err := s.redis.Set(ctx, oauthStateKey+state, data, OAuthStateTTL).Err(); err != nil {
+		return "", "", domain.NewInternalServerError(err)
 	}
 
-	return s.authenticateOAuthUser(provider, userInfo)
+	return authURL, state, nil
 }
 
-func (s *OAuthService) getUserInfo(provider string, token *oauth2.Token) (oauthUserInfo, error) {
-	var userInfoURL string
-	switch provider {
-	case "google":
-		userInfoURL = "https://www.googleapis.com/oauth2/v2/userinfo"
-	case "github":
-		userInfoURL = "https://api.github.com/user"
-	default:
-		return oauthUserInfo{}, errors.New("unsupported provider")
-	}
+// HandleCallback завершает вход. state из URL должен совпасть со state из браузера
+// и с попыткой в Redis; попытка удаляется сразу, поэтому повторить callback нельзя.
+func (s *OAuthService) HandleCallback(ctx context.Context, providerName, code, state, browserState string, device domain.DeviceInfo) (domain.SignInResult, error) {
+	ctx, span := tracer.Start(ctx, "OAuthService.HandleCallback")
+	defer span.End()
 
-	client := http.Client{}
-	req, err := http.NewRequest("GET", userInfoURL, nil)
+	provider, err := s.providers.get(providerName)
 	if err != nil {
-		return oauthUserInfo{}, err
+		return domain.SignInResult{}, err
 	}
 
-	req.Header.Set("Authorization", "Bearer "+token.AccessToken)
-	resp, err := client.Do(req)
+	attempt, err := s.consumeState(ctx, providerName, state, browserState)
 	if err != nil {
-		return oauthUserInfo{}, err
+		return domain.SignInResult{}, err
 	}
-	defer resp.Body.Close()
 
-	body, err := io.ReadAll(resp.Body)
+	userInfo, err := provider.exchange(oauthContext(ctx), code, attempt.CodeVerifier, attempt.Nonce)
 	if err != nil {
-		return oauthUserInfo{}, err
+		return domain.SignInResult{}, domain.NewOAuthProviderError(err)
 	}
 
-	var res oauthUserInfo
-
-	if provider == "google" {
-		var googleUser struct {
-			ID      string `json:"id"`
-			Email   string `json:"email"`
-			Name    string `json:"name"`
-			Picture string `json:"picture"`
-		}
-		if err := json.Unmarshal(body, &googleUser); err != nil {
-			return oauthUserInfo{}, err
-		}
-		res = oauthUserInfo{
-			ID:      googleUser.ID,
-			Email:   googleUser.Email,
-			Name:    googleUser.Name,
-			Picture: googleUser.Picture,
-		}
-	} else if provider == "github" {
-		var githubUser struct {
-			ID        int    `json:"id"`
-			Login     string `json:"login"`
-			Name      string `json:"name"`
-			AvatarURL string `json:"avatar_url"`
-			Email     string `json:"email"`
-		}
-		if err := json.Unmarshal(body, &githubUser); err != nil {
-			return oauthUserInfo{}, err
-		}
-		res = oauthUserInfo{
-			ID:      fmt.Sprintf("%d", githubUser.ID),
-			Name:    githubUser.Name,
-			Picture: githubUser.AvatarURL,
-			Email:   githubUser.Email,
+	if attempt.LinkUserId != 0 {
+		identity, err := s.linkIdentity(ctx, attempt.LinkUserId, userInfo)
+		if err != nil {
+			return domain.SignInResult{}, err
 		}
-		if res.Name == "" {
-			res.Name = githubUser.Login
-		}
-		if res.Email == "" {
-			email, _ := s.getGitHubEmail(token.AccessToken)
-			res.Email = email
+		// У гостя появился способ входа — аккаунт больше не гостевой
+		if err := s.repo.ClearGuest(ctx, attempt.LinkUserId); err != nil {
+			return domain.SignInResult{}, domain.NewInternalServerError(err)
 		}
+		return domain.SignInResult{Linked: &identity}, nil
 	}
 
-	return res, nil
+	return s.authenticateOAuthUser(ctx, userInfo, device)
 }
 
-func (s *OAuthService) getGitHubEmail(accessToken string) (string, error) {
-	req, _ := http.NewRequest("GET", "https://api.github.com/user/emails", nil)
-	req.Header.Set("Authorization", "Bearer "+accessToken)
+func (s *OAuthService) consumeState(ctx context.Context, provider, state, browserState string) (oauthAttempt, error) {
+	if state == "" || subtle.ConstantTimeCompare([]byte(state), []byte(browserState)) != 1 {
+		return oauthAttempt{}, domain.ErrInvalidOAuthState
+	}
 
-	resp, err := http.DefaultClient.Do(req)
+	data, err := s.redis.GetDel(ctx, oauthStateKey+state).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return oauthAttempt{}, domain.ErrInvalidOAuthState
+	}
 	if err != nil {
-		return "", err
+		return oauthAttempt{}, domain.NewInternalServerError(err)
 	}
-	defer resp.Body.Close()
 
-	var emails []struct {
-		Email    string `json:"email"`
-		Primary  bool   `json:"primary"`
-		Verified bool   `json:"verified"`
+	var attempt oauthAttempt
+	if err := json.Unmarshal(data, &attempt); err != nil {
+		return oauthAttempt{}, domain.NewInternalServerError(err)
 	}
-	if err := json.NewDecoder(resp.Body).Decode(&emails); err != nil {
-		return "", err
+	if attempt.Provider != provider {
+		logger.FromContext(ctx).Warnf("oauth state issued for %q used with %q callback", attempt.Provider, provider)
+		return oauthAttempt{}, domain.ErrInvalidOAuthState
 	}
 
-	for _, e := range emails {
-		if e.Primary && e.Verified {
-			return e.Email, nil
-		}
-	}
-	return "", errors.New("no email found")
+	return attempt, nil
 }
 
-func (s *OAuthService) authenticateOAuthUser(provider string, userInfo oauthUserInfo) (domain.ResponseTokens, error) {
-	// 1. Пытаемся найти по OAuth ID
-	user, err := s.repo.GetUserByOAuth(provider, userInfo.ID)
+func (s *OAuthService) authenticateOAuthUser(ctx context.Context, userInfo domain.OAuthUserInfo, device domain.DeviceInfo) (domain.SignInResult, error) {
+	// 1. Уже привязанный способ входа
+	identity, err := s.identities.GetIdentity(ctx, string(userInfo.Provider), userInfo.ID)
 	if err == nil {
-		return s.authService.GenerateTokensForUser(user.ID) // Метод должен быть в AuthService
+		if err := s.identities.TouchIdentity(ctx, identity.ID); err != nil {
+			logger.FromContext(ctx).Errorf("failed to update identity %d last use: %s", identity.ID, err.Error())
+		}
+		return s.authService.GenerateTokensForUser(ctx, identity.UserID, device)
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return domain.SignInResult{}, domain.NewInternalServerError(err)
 	}
 
-	// 2. Пытаемся найти по Email (привязка аккаунта)
+	// 2. Аккаунт с таким же email. Привязываем автоматически, только если почту
+	// подтвердили обе стороны, иначе это путь к захвату чужого аккаунта.
 	if userInfo.Email != "" {
-		user, err = s.repo.GetUserByEmail(userInfo.Email)
+		user, err := s.repo.GetUserByEmail(ctx, userInfo.Email)
 		if err == nil {
-			// В реальности здесь может быть логика обновления OAuthID для существующего юзера
-			return s.authService.GenerateTokensForUser(user.ID)
+			if !userInfo.EmailVerified || !user.EmailVerified {
+				return domain.SignInResult{}, domain.ErrIdentityEmailConflict
+			}
+
+			identity, err := s.linkIdentity(ctx, user.ID, userInfo)
+			if err != nil {
+				return domain.SignInResult{}, err
+			}
+			logger.FromContext(ctx).Infof("%s identity auto-linked to user %d by verified email", userInfo.Provider, identity.UserID)
+			return s.authService.GenerateTokensForUser(ctx, user.ID, device)
+		}
+		if !errors.Is(err, sql.ErrNoRows) {
+			return domain.SignInResult{}, domain.NewInternalServerError(err)
 		}
 	}
 
-	// 3. Создаем нового пользователя
-	newUser := domain.User{
-		Email:         userInfo.Email,
-		OAuthProvider: &provider,
-		OAuthID:       &userInfo.ID,
+	// 3. Создаем нового пользователя. Неподтвержденную почту не сохраняем,
+	// чтобы она не заняла адрес настоящего владельца.
+	newUser := domain.User{EmailVerified: userInfo.EmailVerified}
+	if userInfo.EmailVerified {
+		newUser.Email = userInfo.Email
 	}
 
-	id, err := s.repo.CreateOAuthUser(newUser)
+	id, err := s.repo.CreateOAuthUser(ctx, newUser, newIdentity(0, userInfo))
 	if err != nil {
-		return domain.ResponseTokens{}, err
+		return domain.SignInResult{}, domain.NewInternalServerError(err)
 	}
 
 	// Создаем начальные настройки профиля
 	// Мы передаем имя и иконку, полученные от провайдера
-	if err := s.authService.settingsService.CreateInitialUserSettings(id, userInfo.Name); err != nil {
+	if err := s.authService.settingsService.CreateInitialUserSettings(ctx, id, userInfo.Name, userInfo.Picture); err != nil {
 		// Логируем, но не прерываем вход
+		logger.FromContext(ctx).Errorf("failed to create settings for user %d: %s", id, err.Error())
+	}
+
+	return s.authService.GenerateTokensForUser(ctx, id, device)
+}
+
+// linkIdentity привязывает аккаунт провайдера к пользователю userId.
+// Аккаунт провайдера, уже привязанный к другому пользователю, не переносится.
+func (s *OAuthService) linkIdentity(ctx context.Context, userId int, userInfo domain.OAuthUserInfo) (domain.Identity, error) {
+	existing, err := s.identities.GetIdentity(ctx, string(userInfo.Provider), userInfo.ID)
+	if err == nil {
+		if existing.UserID != userId {
+			return domain.Identity{}, domain.ErrIdentityAlreadyLinked
+		}
+		return existing, nil
+	}
+	if !errors.Is(err, sql.ErrNoRows) {
+		return domain.Identity{}, domain.NewInternalServerError(err)
+	}
+
+	identity := newIdentity(userId, userInfo)
+	if err := s.identities.CreateIdentity(ctx, identity); err != nil {
+		// UNIQUE (user_id, provider): к аккаунту уже привязан другой аккаунт этого провайдера
+		var pqErr *pq.Error
+		if errors.As(err, &pqErr) && pqErr.Code == "23505" {
+			return domain.Identity{}, domain.ErrIdentityAlreadyLinked
+		}
+		return domain.Identity{}, domain.NewInternalServerError(err)
 	}
 
-	return s.authService.GenerateTokensForUser(id)
+	identity.CreatedAt = time.Now()
+	return identity, nil
+}
+
+func newIdentity(userId int, userInfo domain.OAuthUserInfo) domain.Identity {
+	identity := domain.Identity{
+		UserID:         userId,
+		Provider:       string(userInfo.Provider),
+		ProviderUserID: userInfo.ID,
+	}
+	if userInfo.Email != "" {
+		email := userInfo.Email
+		identity.Email = &email
+	}
+	return identity
 }
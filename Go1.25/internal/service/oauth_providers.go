@@ -0,0 +1,508 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/coreos/go-oidc/v3/oidc"
+	"github.com/sirupsen/logrus"
+	"github.com/spf13/viper"
+	"go.opentelemetry.io/contrib/instrumentation/net/http/otelhttp"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"golang.org/x/oauth2"
+)
+
+const (
+	OAuthProviderTypeOIDC   = "oidc"
+	OAuthProviderTypeOAuth2 = "oauth2"
+)
+
+// oauthHTTPClient — запросы к провайдерам (discovery, обмен кода, userinfo) не должны висеть вечно.
+// Каждый запрос — спан с заголовком traceparent.
+var oauthHTTPClient = &http.Client{
+	Timeout:   10 * time.Second,
+	Transport: otelhttp.NewTransport(http.DefaultTransport),
+}
+
+var oauthProviderName = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+// OAuthClaimMapping указывает, из каких полей ответа провайдера брать данные пользователя.
+// Вложенные поля пишутся через точку (user.id), альтернативы — через | (name|login).
+type OAuthClaimMapping struct {
+	ID            string `mapstructure:"id"`
+	Email         string `mapstructure:"email"`
+	EmailVerified string `mapstructure:"emailVerified"`
+	Name          string `mapstructure:"name"`
+	Picture       string `mapstructure:"picture"`
+}
+
+// OAuthProviderConfig описывает одного провайдера из config.yml (секция oauth.providers).
+type OAuthProviderConfig struct {
+	// Type: oidc — endpoints и ключи берутся из discovery по issuer, oauth2 — endpoints задаются явно
+	Type     string   `mapstructure:"type"`
+	Issuer   string   `mapstructure:"issuer"`
+	ClientID string   `mapstructure:"clientID"`
+	Scopes   []string `mapstructure:"scopes"`
+	// ClientSecretEnv — имя переменной окружения с client secret
+	ClientSecretEnv string `mapstructure:"clientSecretEnv"`
+	// ClientSecret — секрет прямо в конфиге или в OAUTH_<ИМЯ>_CLIENTSECRET (прежний формат),
+	// если переменная ClientSecretEnv не задана
+	ClientSecret string `mapstructure:"clientSecret"`
+	// RedirectURL по умолчанию — <oauth.baseURL>/auth/oauth/<имя>/callback
+	RedirectURL string `mapstructure:"redirectURL"`
+
+	AuthURL     string `mapstructure:"authURL"`
+	TokenURL    string `mapstructure:"tokenURL"`
+	UserInfoURL string `mapstructure:"userInfoURL"`
+	// EmailsURL — список адресов в формате GitHub (/user/emails) для поиска подтвержденного основного email
+	EmailsURL string `mapstructure:"emailsURL"`
+
+	Claims OAuthClaimMapping `mapstructure:"claims"`
+	// TrustEmail — провайдер отдает только подтвержденные адреса, отдельного признака в ответе нет
+	TrustEmail bool `mapstructure:"trustEmail"`
+}
+
+type OAuthConfig struct {
+	BaseURL   string                         `mapstructure:"baseURL"`
+	Providers map[string]OAuthProviderConfig `mapstructure:"providers"`
+}
+
+// defaultOIDCClaims — стандартные claims OpenID Connect
+var defaultOIDCClaims = OAuthClaimMapping{
+	ID:            "sub",
+	Email:         "email",
+	EmailVerified: "email_verified",
+	Name:          "name|preferred_username",
+	Picture:       "picture",
+}
+
+type oauthProvider struct {
+	name   string
+	cfg    OAuthProviderConfig
+	oauth2 oauth2.Config
+
+	// Для OIDC discovery выполняется при первом использовании: провайдер
+	// может быть недоступен при старте, и это не должно мешать остальным способам входа.
+	mu       sync.Mutex
+	oidc     *oidc.Provider
+	verifier *oidc.IDTokenVerifier
+}
+
+// OAuthProviders — реестр провайдеров входа, собранный из конфига.
+// Новый провайдер (Discord, VK, Yandex, локальный mock IdP) добавляется только в config.yml.
+type OAuthProviders struct {
+	providers map[string]*oauthProvider
+}
+
+func NewOAuthProviders(cfg OAuthConfig) (*OAuthProviders, error) {
+	registry := &OAuthProviders{providers: make(map[string]*oauthProvider)}
+
+	for name, providerCfg := range cfg.Providers {
+		providerCfg = resolveOAuthKeys(name, providerCfg)
+		if providerCfg.ClientID == "" {
+			// Секрет задан, а clientID нет — ошибка конфигурации, а не локальный запуск
+			if providerCfg.ClientSecret != "" || (providerCfg.ClientSecretEnv != "" && os.Getenv(providerCfg.ClientSecretEnv) != "") {
+				return nil, fmt.Errorf("oauth provider %q: client secret is set but clientID is empty", name)
+			}
+			// Провайдер описан, но не зарегистрирован у нас (локальный запуск) — просто не показываем его
+			logrus.Warnf("oauth provider %q has no clientID, skipping", name)
+			continue
+		}
+		provider, err := newOAuthProvider(name, providerCfg, cfg.BaseURL)
+		if err != nil {
+			return nil, fmt.Errorf("oauth provider %q: %w", name, err)
+		}
+		registry.providers[name] = provider
+	}
+
+	names := registry.Names()
+	logrus.Infof("oauth providers: %s", strings.Join(names, ", "))
+
+	return registry, nil
+}
+
+// resolveOAuthKeys дополняет конфиг провайдера переменными окружения. UnmarshalKey("oauth")
+// их не видит, поэтому clientID читается отдельно: OAUTH_PROVIDERS_<ИМЯ>_CLIENTID.
+// Если он не задан, работают ключи прежнего формата oauth.<имя>.clientID, oauth.<имя>.clientSecret,
+// oauth.<имя>.redirectURL (OAUTH_<ИМЯ>_CLIENTID, OAUTH_<ИМЯ>_CLIENTSECRET, OAUTH_<ИМЯ>_REDIRECTURL).
+func resolveOAuthKeys(name string, cfg OAuthProviderConfig) OAuthProviderConfig {
+	if clientID := viper.GetString("oauth.providers." + name + ".clientID"); clientID != "" {
+		cfg.ClientID = clientID
+	}
+
+	legacy := "oauth." + name + "."
+	if cfg.ClientID == "" {
+		cfg.ClientID = viper.GetString(legacy + "clientID")
+	}
+	if cfg.ClientSecret == "" {
+		cfg.ClientSecret = viper.GetString(legacy + "clientSecret")
+	}
+	if cfg.RedirectURL == "" {
+		cfg.RedirectURL = viper.GetString(legacy + "redirectURL")
+	}
+	return cfg
+}
+
+func newOAuthProvider(name string, cfg OAuthProviderConfig, baseURL string) (*oauthProvider, error) {
+	// telegram занят входом через Telegram Login Widget (см. telegram.go)
+	if !oauthProviderName.MatchString(name) || name == domain.IdentityPassword || name == string(domain.OAuthProviderTelegram) {
+		return nil, errors.New("invalid provider name")
+	}
+	var secret string
+	if cfg.ClientSecretEnv != "" {
+		secret = os.Getenv(cfg.ClientSecretEnv)
+	}
+	if secret == "" {
+		secret = cfg.ClientSecret
+	}
+	if secret == "" && cfg.ClientSecretEnv != "" {
+		logrus.Warnf("oauth provider %q: %s is empty", name, cfg.ClientSecretEnv)
+	}
+
+	redirectURL := cfg.RedirectURL
+	if redirectURL == "" {
+		redirectURL = strings.TrimRight(baseURL, "/") + "/auth/oauth/" + name + "/callback"
+	}
+
+	provider := &oauthProvider{
+		name: name,
+		cfg:  cfg,
+		oauth2: oauth2.Config{
+			ClientID:     cfg.ClientID,
+			ClientSecret: secret,
+			RedirectURL:  redirectURL,
+			Scopes:       cfg.Scopes,
+		},
+	}
+
+	switch cfg.Type {
+	case OAuthProviderTypeOIDC:
+		if cfg.Issuer == "" {
+			return nil, errors.New("issuer is required for oidc")
+		}
+		if len(provider.oauth2.Scopes) == 0 {
+			provider.oauth2.Scopes = []string{oidc.ScopeOpenID, "email", "profile"}
+		}
+		provider.cfg.Claims = mergeClaimMapping(cfg.Claims, defaultOIDCClaims)
+
+	case OAuthProviderTypeOAuth2:
+		if cfg.AuthURL == "" || cfg.TokenURL == "" || cfg.UserInfoURL == "" {
+			return nil, errors.New("authURL, tokenURL and userInfoURL are required for oauth2")
+		}
+		if cfg.Claims.ID == "" {
+			return nil, errors.New("claims.id is required for oauth2")
+		}
+		provider.oauth2.Endpoint = oauth2.Endpoint{AuthURL: cfg.AuthURL, TokenURL: cfg.TokenURL}
+
+	default:
+		return nil, fmt.Errorf("unsupported type %q", cfg.Type)
+	}
+
+	return provider, nil
+}
+
+// mergeClaimMapping дополняет незаданные поля значениями по умолчанию
+func mergeClaimMapping(mapping, defaults OAuthClaimMapping) OAuthClaimMapping {
+	if mapping.ID == "" {
+		mapping.ID = defaults.ID
+	}
+	if mapping.Email == "" {
+		mapping.Email = defaults.Email
+	}
+	if mapping.EmailVerified == "" {
+		mapping.EmailVerified = defaults.EmailVerified
+	}
+	if mapping.Name == "" {
+		mapping.Name = defaults.Name
+	}
+	if mapping.Picture == "" {
+		mapping.Picture = defaults.Picture
+	}
+	return mapping
+}
+
+// Names возвращает имена настроенных провайдеров по алфавиту
+func (r *OAuthProviders) Names() []string {
+	names := make([]string, 0, len(r.providers))
+	for name := range r.providers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func (r *OAuthProviders) get(name string) (*oauthProvider, error) {
+	provider, ok := r.providers[name]
+	if !ok {
+		return nil, domain.ErrOAuthProviderNotFound
+	}
+	return provider, nil
+}
+
+// oauthContext добавляет к контексту запроса HTTP клиент для oauth2 и oidc
+func oauthContext(ctx context.Context) context.Context {
+	ctx = context.WithValue(ctx, oauth2.HTTPClient, oauthHTTPClient)
+	return oidc.ClientContext(ctx, oauthHTTPClient)
+}
+
+// config возвращает настройки oauth2, при необходимости выполняя OIDC discovery
+func (p *oauthProvider) config(ctx context.Context) (*oauth2.Config, error) {
+	if p.cfg.Type != OAuthProviderTypeOIDC {
+		return &p.oauth2, nil
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if p.oidc == nil {
+		discovered, err := oidc.NewProvider(ctx, p.cfg.Issuer)
+		if err != nil {
+			return nil, fmt.Errorf("oidc discovery: %w", err)
+		}
+		p.oidc = discovered
+		p.verifier = discovered.Verifier(&oidc.Config{ClientID: p.cfg.ClientID})
+		p.oauth2.Endpoint = discovered.Endpoint()
+	}
+
+	return &p.oauth2, nil
+}
+
+// authCodeURL строит ссылку на страницу входа провайдера. nonce используется только для OIDC.
+func (p *oauthProvider) authCodeURL(ctx context.Context, state, codeVerifier, nonce string) (string, error) {
+	config, err := p.config(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	options := []oauth2.AuthCodeOption{oauth2.S256ChallengeOption(codeVerifier)}
+	if p.cfg.Type == OAuthProviderTypeOIDC {
+		options = append(options, oidc.Nonce(nonce))
+	}
+	return config.AuthCodeURL(state, options...), nil
+}
+
+// exchange меняет код на токены и возвращает данные пользователя.
+// Для OIDC данные берутся из проверенного ID токена (подпись, issuer, audience, срок, nonce).
+func (p *oauthProvider) exchange(ctx context.Context, code, codeVerifier, nonce string) (info domain.OAuthUserInfo, err error) {
+	ctx, span := tracer.Start(ctx, "oauth.exchange", trace.WithAttributes(attribute.String("oauth.provider", p.name)))
+	defer func() {
+		if err != nil {
+			span.RecordError(err)
+			span.SetStatus(codes.Error, "oauth exchange failed")
+		}
+		span.End()
+	}()
+
+	config, err := p.config(ctx)
+	if err != nil {
+		return domain.OAuthUserInfo{}, err
+	}
+
+	token, err := config.Exchange(ctx, code, oauth2.VerifierOption(codeVerifier))
+	if err != nil {
+		return domain.OAuthUserInfo{}, fmt.Errorf("code exchange: %w", err)
+	}
+
+	var claims map[string]any
+	if p.cfg.Type == OAuthProviderTypeOIDC {
+		claims, err = p.idTokenClaims(ctx, token, nonce)
+	} else {
+		claims, err = p.fetchJSON(ctx, token, p.cfg.UserInfoURL)
+	}
+	if err != nil {
+		return domain.OAuthUserInfo{}, err
+	}
+
+	mapping := p.cfg.Claims
+	info = domain.OAuthUserInfo{
+		Provider:      domain.OAuthProvider(p.name),
+		ID:            claimString(claims, mapping.ID),
+		Email:         claimString(claims, mapping.Email),
+		EmailVerified: claimBool(claims, mapping.EmailVerified),
+		Name:          claimString(claims, mapping.Name),
+		Picture:       claimString(claims, mapping.Picture),
+	}
+	if info.ID == "" {
+		return domain.OAuthUserInfo{}, errors.New("provider response has no user id")
+	}
+	if p.cfg.TrustEmail && info.Email != "" {
+		info.EmailVerified = true
+	}
+
+	// Основной email в профиле может быть не подтвержден (или скрыт) — ищем подтвержденный
+	if !info.EmailVerified && p.cfg.EmailsURL != "" {
+		if email, err := p.fetchVerifiedEmail(ctx, token); err == nil {
+			info.Email = email
+			info.EmailVerified = true
+		}
+	}
+
+	return info, nil
+}
+
+func (p *oauthProvider) idTokenClaims(ctx context.Context, token *oauth2.Token, nonce string) (map[string]any, error) {
+	rawIDToken, ok := token.Extra("id_token").(string)
+	if !ok || rawIDToken == "" {
+		return nil, errors.New("token response has no id_token")
+	}
+
+	idToken, err := p.verifier.Verify(ctx, rawIDToken)
+	if err != nil {
+		return nil, fmt.Errorf("id_token: %w", err)
+	}
+	if idToken.Nonce != nonce {
+		return nil, errors.New("id_token: nonce mismatch")
+	}
+
+	var claims map[string]any
+	if err := idToken.Claims(&claims); err != nil {
+		return nil, err
+	}
+
+	// Некоторые провайдеры не кладут email в ID токен — дополняем из userinfo того же пользователя
+	if claimString(claims, p.cfg.Claims.Email) == "" && p.oidc.UserInfoEndpoint() != "" {
+		userInfo, err := p.oidc.UserInfo(ctx, oauth2.StaticTokenSource(token))
+		if err == nil && userInfo.Subject == idToken.Subject {
+			var extra map[string]any
+			if err := userInfo.Claims(&extra); err == nil {
+				for key, value := range extra {
+					if _, exists := claims[key]; !exists {
+						claims[key] = value
+					}
+				}
+			}
+		}
+	}
+
+	return claims, nil
+}
+
+func (p *oauthProvider) fetchJSON(ctx context.Context, token *oauth2.Token, url string) (map[string]any, error) {
+	body, err := p.get(ctx, token, url)
+	if err != nil {
+		return nil, err
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(body))
+	decoder.UseNumber()
+	var claims map[string]any
+	if err := decoder.Decode(&claims); err != nil {
+		return nil, fmt.Errorf("userinfo: %w", err)
+	}
+	return claims, nil
+}
+
+func (p *oauthProvider) fetchVerifiedEmail(ctx context.Context, token *oauth2.Token) (string, error) {
+	body, err := p.get(ctx, token, p.cfg.EmailsURL)
+	if err != nil {
+		return "", err
+	}
+
+	var emails []struct {
+		Email    string `json:"email"`
+		Primary  bool   `json:"primary"`
+		Verified bool   `json:"verified"`
+	}
+	if err := json.Unmarshal(body, &emails); err != nil {
+		return "", err
+	}
+
+	for _, e := range emails {
+		if e.Primary && e.Verified {
+			return e.Email, nil
+		}
+	}
+	return "", errors.New("no verified primary email")
+}
+
+func (p *oauthProvider) get(ctx context.Context, token *oauth2.Token, url string) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+	token.SetAuthHeader(req)
+
+	resp, err := oauthHTTPClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s: unexpected status %d", url, resp.StatusCode)
+	}
+	return body, nil
+}
+
+// claimValue ищет первое непустое значение среди альтернатив (a|b), поддерживая вложенные поля (a.b)
+func claimValue(claims map[string]any, path string) any {
+	if path == "" {
+		return nil
+	}
+
+	for _, alternative := range strings.Split(path, "|") {
+		var current any = claims
+		for _, key := range strings.Split(alternative, ".") {
+			object, ok := current.(map[string]any)
+			if !ok {
+				current = nil
+				break
+			}
+			current = object[key]
+		}
+		if current != nil && current != "" {
+			return current
+		}
+	}
+	return nil
+}
+
+func claimString(claims map[string]any, path string) string {
+	switch value := claimValue(claims, path).(type) {
+	case string:
+		return value
+	case json.Number:
+		return value.String()
+	case float64:
+		// ID токен декодируется без UseNumber, целые id не должны превращаться в 1.2e+07
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	case bool:
+		if value {
+			return "true"
+		}
+		return "false"
+	default:
+		return ""
+	}
+}
+
+func claimBool(claims map[string]any, path string) bool {
+	switch value := claimValue(claims, path).(type) {
+	case bool:
+		return value
+	case string:
+		return value == "true"
+	default:
+		return false
+	}
+}
@@ -0,0 +1,144 @@
+package service
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/spf13/viper"
+)
+
+func decodeClaims(t *testing.T, data string, useNumber bool) map[string]any {
+	t.Helper()
+	decoder := json.NewDecoder(strings.NewReader(data))
+	if useNumber {
+		decoder.UseNumber()
+	}
+	var claims map[string]any
+	if err := decoder.Decode(&claims); err != nil {
+		t.Fatal(err)
+	}
+	return claims
+}
+
+func TestClaimValue(t *testing.T) {
+	claims := decodeClaims(t, `{
+		"sub": "123",
+		"name": "",
+		"login": "octocat",
+		"user": {"id": 42, "profile": {"email": "a@example.com"}},
+		"flat": "not an object"
+	}`, true)
+
+	tests := []struct {
+		name string
+		path string
+		want any
+	}{
+		{"top level", "sub", "123"},
+		{"nested", "user.profile.email", "a@example.com"},
+		{"empty string falls through to alternative", "name|login", "octocat"},
+		{"first present alternative wins", "login|sub", "octocat"},
+		{"missing key", "missing", nil},
+		{"missing nested key", "user.missing", nil},
+		{"path through non-object", "flat.id", nil},
+		{"all alternatives missing", "a|b.c", nil},
+		{"empty path", "", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := claimValue(claims, tt.path); got != tt.want {
+				t.Fatalf("claimValue(%q) = %#v, want %#v", tt.path, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestClaimString(t *testing.T) {
+	// userinfo декодируется с UseNumber, ID токен — без него
+	withNumbers := decodeClaims(t, `{"id": 12345678, "verified": true}`, true)
+	withFloats := decodeClaims(t, `{"id": 12345678, "verified": false}`, false)
+
+	if got := claimString(withNumbers, "id"); got != "12345678" {
+		t.Errorf("json.Number id = %q", got)
+	}
+	if got := claimString(withFloats, "id"); got != "12345678" {
+		t.Errorf("float64 id = %q, want without exponent", got)
+	}
+	if got := claimString(withNumbers, "verified"); got != "true" {
+		t.Errorf("bool = %q", got)
+	}
+	if got := claimString(withFloats, "verified"); got != "false" {
+		t.Errorf("bool = %q", got)
+	}
+	if got := claimString(withNumbers, "missing"); got != "" {
+		t.Errorf("missing = %q", got)
+	}
+}
+
+func TestClaimBool(t *testing.T) {
+	claims := decodeClaims(t, `{"a": true, "b": "true", "c": "yes", "d": 1, "e": false}`, true)
+
+	for path, want := range map[string]bool{"a": true, "b": true, "c": false, "d": false, "e": false, "missing": false} {
+		if got := claimBool(claims, path); got != want {
+			t.Errorf("claimBool(%q) = %v, want %v", path, got, want)
+		}
+	}
+}
+
+func githubProviderConfig() OAuthProviderConfig {
+	return OAuthProviderConfig{
+		Type:        OAuthProviderTypeOAuth2,
+		AuthURL:     "https://github.com/login/oauth/authorize",
+		TokenURL:    "https://github.com/login/oauth/access_token",
+		UserInfoURL: "https://api.github.com/user",
+		Claims:      OAuthClaimMapping{ID: "id"},
+	}
+}
+
+func TestNewOAuthProvidersLegacyKeys(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	// Прежний формат: oauth.github.clientID и oauth.github.clientSecret
+	viper.Set("oauth.github.clientID", "legacy-id")
+	viper.Set("oauth.github.clientSecret", "legacy-secret")
+
+	registry, err := NewOAuthProviders(OAuthConfig{
+		BaseURL:   "http://localhost:8080",
+		Providers: map[string]OAuthProviderConfig{"github": githubProviderConfig()},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	provider := registry.providers["github"]
+	if provider == nil {
+		t.Fatal("github provider is not registered")
+	}
+	if provider.oauth2.ClientID != "legacy-id" || provider.oauth2.ClientSecret != "legacy-secret" {
+		t.Fatalf("client = %q / %q", provider.oauth2.ClientID, provider.oauth2.ClientSecret)
+	}
+}
+
+func TestNewOAuthProvidersSecretWithoutClientID(t *testing.T) {
+	t.Cleanup(viper.Reset)
+	t.Setenv("TEST_GITHUB_SECRET", "secret")
+
+	cfg := githubProviderConfig()
+	cfg.ClientSecretEnv = "TEST_GITHUB_SECRET"
+	_, err := NewOAuthProviders(OAuthConfig{Providers: map[string]OAuthProviderConfig{"github": cfg}})
+	if err == nil {
+		t.Fatal("expected error for provider with secret but without clientID")
+	}
+}
+
+func TestNewOAuthProvidersSkipsUnconfigured(t *testing.T) {
+	t.Cleanup(viper.Reset)
+
+	registry, err := NewOAuthProviders(OAuthConfig{Providers: map[string]OAuthProviderConfig{"github": githubProviderConfig()}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(registry.Names()) != 0 {
+		t.Fatalf("providers = %v, want none", registry.Names())
+	}
+}
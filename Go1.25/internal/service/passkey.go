@@ -0,0 +1,368 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/ArtemChadaev/SeeThisGame/internal/logger"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/webauthn"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// passkeyCeremonyTTL — время на подтверждение в браузере/на телефоне
+	passkeyCeremonyTTL = 5 * time.Minute
+
+	passkeyCeremonyKey = "passkey_ceremony:"
+)
+
+// WebAuthnConfig — секция webauthn из config.yml
+type WebAuthnConfig struct {
+	// RPID — домен сайта без схемы и порта, например seethisgame.com
+	RPID          string   `mapstructure:"rpId"`
+	RPDisplayName string   `mapstructure:"rpDisplayName"`
+	RPOrigins     []string `mapstructure:"rpOrigins"`
+}
+
+// NewWebAuthn создает relying party. Ключи доступа всегда discoverable и с проверкой
+// пользователя (PIN, биометрия), поэтому вход по ним не требует ни email, ни второго фактора.
+func NewWebAuthn(cfg WebAuthnConfig) (*webauthn.WebAuthn, error) {
+	return webauthn.New(&webauthn.Config{
+		RPID:          cfg.RPID,
+		RPDisplayName: cfg.RPDisplayName,
+		RPOrigins:     cfg.RPOrigins,
+		AuthenticatorSelection: protocol.AuthenticatorSelection{
+			RequireResidentKey: protocol.ResidentKeyRequired(),
+			ResidentKey:        protocol.ResidentKeyRequirementRequired,
+			UserVerification:   protocol.VerificationRequired,
+		},
+	})
+}
+
+// passkeyCeremony — состояние начатой церемонии в Redis
+type passkeyCeremony struct {
+	// UserId заполнен только для регистрации: вход начинается без пользователя
+	UserId  int                  `json:"userId,omitempty"`
+	Session webauthn.SessionData `json:"session"`
+}
+
+// passkeyUser адаптирует пользователя к интерфейсу webauthn.User
+type passkeyUser struct {
+	id          int
+	email       string
+	passkeys    []domain.Passkey
+	credentials []webauthn.Credential
+}
+
+func (u *passkeyUser) WebAuthnID() []byte {
+	return passkeyUserHandle(u.id)
+}
+
+func (u *passkeyUser) WebAuthnName() string {
+	if u.email == "" {
+		return "user-" + strconv.Itoa(u.id)
+	}
+	return u.email
+}
+
+func (u *passkeyUser) WebAuthnDisplayName() string {
+	return u.WebAuthnName()
+}
+
+func (u *passkeyUser) WebAuthnCredentials() []webauthn.Credential {
+	return u.credentials
+}
+
+// passkeyUserHandle — user handle, который аутентификатор хранит вместе с ключом
+// и возвращает при входе. Это id пользователя, без email и других личных данных.
+func passkeyUserHandle(userId int) []byte {
+	return []byte(strconv.Itoa(userId))
+}
+
+type PasskeyService struct {
+	repo        domain.PasskeyRepository
+	authRepo    domain.AuthorizationRepository
+	authService *AuthService
+	webAuthn    *webauthn.WebAuthn
+	redis       *redis.Client
+}
+
+func NewPasskeyService(repo domain.PasskeyRepository, authRepo domain.AuthorizationRepository, authService *AuthService, webAuthn *webauthn.WebAuthn, redis *redis.Client) *PasskeyService {
+	return &PasskeyService{
+		repo:        repo,
+		authRepo:    authRepo,
+		authService: authService,
+		webAuthn:    webAuthn,
+		redis:       redis,
+	}
+}
+
+// BeginPasskeyRegistration начинает добавление ключа доступа текущему пользователю.
+// Уже добавленные ключи исключаются, чтобы аутентификатор не создал дубликат.
+func (s *PasskeyService) BeginPasskeyRegistration(ctx context.Context, userId int) (domain.PasskeyCeremony, error) {
+	ctx, span := tracer.Start(ctx, "PasskeyService.BeginPasskeyRegistration")
+	defer span.End()
+
+	user, err := s.loadUser(ctx, userId)
+	if err != nil {
+		return domain.PasskeyCeremony{}, err
+	}
+
+	exclusions := webauthn.Credentials(user.credentials).CredentialDescriptors()
+	options, session, err := s.webAuthn.BeginRegistration(user, webauthn.WithExclusions(exclusions))
+	if err != nil {
+		return domain.PasskeyCeremony{}, domain.NewInternalServerError(err)
+	}
+
+	return s.saveCeremony(ctx, userId, options, session)
+}
+
+// FinishPasskeyRegistration проверяет ответ аутентификатора и сохраняет ключ.
+func (s *PasskeyService) FinishPasskeyRegistration(ctx context.Context, userId int, ceremonyId, name string, credential json.RawMessage) (domain.Passkey, error) {
+	ctx, span := tracer.Start(ctx, "PasskeyService.FinishPasskeyRegistration")
+	defer span.End()
+
+	ceremony, err := s.takeCeremony(ctx, ceremonyId)
+	if err != nil {
+		return domain.Passkey{}, err
+	}
+	if ceremony.UserId != userId {
+		return domain.Passkey{}, domain.ErrInvalidPasskey
+	}
+
+	user, err := s.loadUser(ctx, userId)
+	if err != nil {
+		return domain.Passkey{}, err
+	}
+
+	parsed, err := protocol.ParseCredentialCreationResponseBytes(credential)
+	if err != nil {
+		return domain.Passkey{}, domain.NewInvalidRequestError(err)
+	}
+
+	created, err := s.webAuthn.CreateCredential(user, ceremony.Session, parsed)
+	if err != nil {
+		logger.FromContext(ctx).Infof("passkey registration failed for user %d: %s", userId, err.Error())
+		return domain.Passkey{}, domain.ErrInvalidPasskey
+	}
+
+	data, err := json.Marshal(created)
+	if err != nil {
+		return domain.Passkey{}, domain.NewInternalServerError(err)
+	}
+
+	passkey := domain.Passkey{
+		UserID:       userId,
+		CredentialID: created.ID,
+		Credential:   data,
+		CreatedAt:    time.Now(),
+	}
+	if name != "" {
+		name = truncate(name, maxDeviceInfoLength)
+		passkey.Name = &name
+	}
+
+	passkey.ID, err = s.repo.CreatePasskey(ctx, passkey)
+	if err != nil {
+		return domain.Passkey{}, domain.NewInternalServerError(err)
+	}
+
+	// Ключ доступа — полноценный способ входа, гость становится обычным аккаунтом
+	if err := s.authRepo.ClearGuest(ctx, userId); err != nil {
+		return domain.Passkey{}, domain.NewInternalServerError(err)
+	}
+
+	logger.FromContext(ctx).Infof("passkey %d registered for user %d", passkey.ID, userId)
+	return passkey, nil
+}
+
+// BeginPasskeyLogin начинает вход без email: браузер сам предложит ключи для этого сайта.
+func (s *PasskeyService) BeginPasskeyLogin(ctx context.Context) (domain.PasskeyCeremony, error) {
+	ctx, span := tracer.Start(ctx, "PasskeyService.BeginPasskeyLogin")
+	defer span.End()
+
+	options, session, err := s.webAuthn.BeginDiscoverableLogin(
+		webauthn.WithUserVerification(protocol.VerificationRequired),
+	)
+	if err != nil {
+		return domain.PasskeyCeremony{}, domain.NewInternalServerError(err)
+	}
+
+	return s.saveCeremony(ctx, 0, options, session)
+}
+
+// FinishPasskeyLogin проверяет подпись и выдает токены так же, как вход по паролю.
+// MFA challenge не нужен: ключ с проверкой пользователя уже двухфакторный.
+func (s *PasskeyService) FinishPasskeyLogin(ctx context.Context, ceremonyId string, credential json.RawMessage, device domain.DeviceInfo) (domain.ResponseTokens, error) {
+	ctx, span := tracer.Start(ctx, "PasskeyService.FinishPasskeyLogin")
+	defer span.End()
+
+	ceremony, err := s.takeCeremony(ctx, ceremonyId)
+	if err != nil {
+		return domain.ResponseTokens{}, err
+	}
+	if ceremony.UserId != 0 {
+		// Церемония регистрации, а не входа
+		return domain.ResponseTokens{}, domain.ErrInvalidPasskey
+	}
+
+	parsed, err := protocol.ParseCredentialRequestResponseBytes(credential)
+	if err != nil {
+		return domain.ResponseTokens{}, domain.NewInvalidRequestError(err)
+	}
+
+	// Пользователя определяем по user handle, который вернул аутентификатор
+	var user *passkeyUser
+	handler := func(rawID, userHandle []byte) (webauthn.User, error) {
+		userId, err := strconv.Atoi(string(userHandle))
+		if err != nil {
+			return nil, err
+		}
+		user, err = s.loadUser(ctx, userId)
+		if err != nil {
+			return nil, err
+		}
+		return user, nil
+	}
+
+	_, validated, err := s.webAuthn.ValidatePasskeyLogin(handler, ceremony.Session, parsed)
+	if err != nil {
+		logger.FromContext(ctx).Infof("passkey login failed: %s", err.Error())
+		return domain.ResponseTokens{}, domain.ErrInvalidPasskey
+	}
+
+	passkey, ok := user.findPasskey(validated.ID)
+	if !ok {
+		return domain.ResponseTokens{}, domain.ErrInvalidPasskey
+	}
+
+	// Счетчик подписей не вырос — возможно, ключ скопирован с аутентификатора
+	if validated.Authenticator.CloneWarning {
+		logger.FromContext(ctx).Warnf("passkey %d of user %d: sign counter went backwards, possible cloned authenticator", passkey.ID, user.id)
+		return domain.ResponseTokens{}, domain.ErrInvalidPasskey
+	}
+
+	data, err := json.Marshal(validated)
+	if err != nil {
+		return domain.ResponseTokens{}, domain.NewInternalServerError(err)
+	}
+	if err := s.repo.UpdatePasskeyCredential(ctx, passkey.ID, data); err != nil {
+		return domain.ResponseTokens{}, domain.NewInternalServerError(err)
+	}
+
+	return s.authService.createTokens(ctx, user.id, device)
+}
+
+func (s *PasskeyService) GetPasskeys(ctx context.Context, userId int) ([]domain.Passkey, error) {
+	ctx, span := tracer.Start(ctx, "PasskeyService.GetPasskeys")
+	defer span.End()
+
+	passkeys, err := s.repo.GetPasskeys(ctx, userId)
+	if err != nil {
+		return nil, domain.NewInternalServerError(err)
+	}
+	if passkeys == nil {
+		passkeys = []domain.Passkey{}
+	}
+	return passkeys, nil
+}
+
+func (s *PasskeyService) DeletePasskey(ctx context.Context, userId, id int) error {
+	ctx, span := tracer.Start(ctx, "PasskeyService.DeletePasskey")
+	defer span.End()
+
+	deleted, err := s.repo.DeletePasskey(ctx, userId, id)
+	if err != nil {
+		return domain.NewInternalServerError(err)
+	}
+	if !deleted {
+		return domain.ErrPasskeyNotFound
+	}
+
+	logger.FromContext(ctx).Infof("passkey %d deleted by user %d", id, userId)
+	return nil
+}
+
+// loadUser собирает пользователя вместе с его ключами
+func (s *PasskeyService) loadUser(ctx context.Context, userId int) (*passkeyUser, error) {
+	account, err := s.authRepo.GetUserById(ctx, userId)
+	if err != nil {
+		return nil, domain.ErrUserNotFound
+	}
+
+	passkeys, err := s.repo.GetPasskeys(ctx, userId)
+	if err != nil {
+		return nil, domain.NewInternalServerError(err)
+	}
+
+	user := &passkeyUser{
+		id:          account.ID,
+		email:       account.Email,
+		passkeys:    passkeys,
+		credentials: make([]webauthn.Credential, 0, len(passkeys)),
+	}
+	for _, passkey := range passkeys {
+		var credential webauthn.Credential
+		if err := json.Unmarshal(passkey.Credential, &credential); err != nil {
+			return nil, domain.NewInternalServerError(fmt.Errorf("passkey %d: %w", passkey.ID, err))
+		}
+		user.credentials = append(user.credentials, credential)
+	}
+
+	return user, nil
+}
+
+func (u *passkeyUser) findPasskey(credentialId []byte) (domain.Passkey, bool) {
+	for _, passkey := range u.passkeys {
+		if bytes.Equal(passkey.CredentialID, credentialId) {
+			return passkey, true
+		}
+	}
+	return domain.Passkey{}, false
+}
+
+func (s *PasskeyService) saveCeremony(ctx context.Context, userId int, options any, session *webauthn.SessionData) (domain.PasskeyCeremony, error) {
+	ceremonyId, err := generateRefreshToken()
+	if err != nil {
+		return domain.PasskeyCeremony{}, domain.NewInternalServerError(err)
+	}
+
+	data, err := json.Marshal(passkeyCeremony{UserId: userId, Session: *session})
+	if err != nil {
+		return domain.PasskeyCeremony{}, domain.NewInternalServerError(err)
+	}
+
+	if err := s.redis.Set(ctx, passkeyCeremonyKey+ceremonyId, data, passkeyCeremonyTTL).Err(); err != nil {
+		return domain.PasskeyCeremony{}, domain.NewInternalServerError(err)
+	}
+
+	return domain.PasskeyCeremony{
+		CeremonyID: ceremonyId,
+		Options:    options,
+		ExpiresIn:  int(passkeyCeremonyTTL.Seconds()),
+	}, nil
+}
+
+// takeCeremony достает и сразу удаляет состояние: challenge одноразовый
+func (s *PasskeyService) takeCeremony(ctx context.Context, ceremonyId string) (passkeyCeremony, error) {
+	data, err := s.redis.GetDel(ctx, passkeyCeremonyKey+ceremonyId).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return passkeyCeremony{}, domain.ErrInvalidPasskey
+	}
+	if err != nil {
+		return passkeyCeremony{}, domain.NewInternalServerError(err)
+	}
+
+	var ceremony passkeyCeremony
+	if err := json.Unmarshal(data, &ceremony); err != nil {
+		return passkeyCeremony{}, domain.NewInternalServerError(err)
+	}
+	return ceremony, nil
+}
@@ -0,0 +1,368 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"strconv"
+	"testing"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/go-webauthn/webauthn/protocol"
+	"github.com/go-webauthn/webauthn/protocol/webauthncbor"
+	"github.com/go-webauthn/webauthn/protocol/webauthncose"
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	testRPID   = "localhost"
+	testOrigin = "http://localhost:3000"
+)
+
+// softAuthenticator — программный аутентификатор: один ключ ES256 и счетчик подписей
+type softAuthenticator struct {
+	key          *ecdsa.PrivateKey
+	credentialId []byte
+	userHandle   []byte
+	signCount    uint32
+}
+
+func newSoftAuthenticator(t *testing.T) *softAuthenticator {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	credentialId := make([]byte, 16)
+	if _, err := rand.Read(credentialId); err != nil {
+		t.Fatal(err)
+	}
+	return &softAuthenticator{key: key, credentialId: credentialId}
+}
+
+func (a *softAuthenticator) authData(attested bool) []byte {
+	rpIdHash := sha256.Sum256([]byte(testRPID))
+	flags := protocol.FlagUserPresent | protocol.FlagUserVerified
+	if attested {
+		flags |= protocol.FlagAttestedCredentialData
+	}
+
+	data := append([]byte{}, rpIdHash[:]...)
+	data = append(data, byte(flags))
+	data = binary.BigEndian.AppendUint32(data, a.signCount)
+	return data
+}
+
+func clientData(t *testing.T, ceremonyType protocol.CeremonyType, challenge []byte) []byte {
+	t.Helper()
+	data, err := json.Marshal(map[string]string{
+		"type":      string(ceremonyType),
+		"challenge": base64.RawURLEncoding.EncodeToString(challenge),
+		"origin":    testOrigin,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// create отвечает на navigator.credentials.create: attestation none
+func (a *softAuthenticator) create(t *testing.T, options *protocol.CredentialCreation) json.RawMessage {
+	t.Helper()
+	a.userHandle = options.Response.User.ID.(protocol.URLEncodedBase64)
+
+	publicKey, err := webauthncbor.Marshal(webauthncose.EC2PublicKeyData{
+		PublicKeyData: webauthncose.PublicKeyData{
+			KeyType:   int64(webauthncose.EllipticKey),
+			Algorithm: int64(webauthncose.AlgES256),
+		},
+		Curve:  int64(webauthncose.P256),
+		XCoord: a.key.X.FillBytes(make([]byte, 32)),
+		YCoord: a.key.Y.FillBytes(make([]byte, 32)),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	authData := a.authData(true)
+	authData = append(authData, make([]byte, 16)...) // AAGUID
+	authData = binary.BigEndian.AppendUint16(authData, uint16(len(a.credentialId)))
+	authData = append(authData, a.credentialId...)
+	authData = append(authData, publicKey...)
+
+	attestation, err := webauthncbor.Marshal(map[string]any{
+		"fmt":      "none",
+		"attStmt":  map[string]any{},
+		"authData": authData,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return credentialJSON(t, a.credentialId, map[string]string{
+		"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientData(t, protocol.CreateCeremony, options.Response.Challenge)),
+		"attestationObject": base64.RawURLEncoding.EncodeToString(attestation),
+	})
+}
+
+// get отвечает на navigator.credentials.get с текущим значением счетчика
+func (a *softAuthenticator) get(t *testing.T, options *protocol.CredentialAssertion) json.RawMessage {
+	t.Helper()
+	authData := a.authData(false)
+	clientDataJSON := clientData(t, protocol.AssertCeremony, options.Response.Challenge)
+
+	clientDataHash := sha256.Sum256(clientDataJSON)
+	digest := sha256.Sum256(append(append([]byte{}, authData...), clientDataHash[:]...))
+	signature, err := ecdsa.SignASN1(rand.Reader, a.key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return credentialJSON(t, a.credentialId, map[string]string{
+		"clientDataJSON":    base64.RawURLEncoding.EncodeToString(clientDataJSON),
+		"authenticatorData": base64.RawURLEncoding.EncodeToString(authData),
+		"signature":         base64.RawURLEncoding.EncodeToString(signature),
+		"userHandle":        base64.RawURLEncoding.EncodeToString(a.userHandle),
+	})
+}
+
+func credentialJSON(t *testing.T, credentialId []byte, response map[string]string) json.RawMessage {
+	t.Helper()
+	id := base64.RawURLEncoding.EncodeToString(credentialId)
+	data, err := json.Marshal(map[string]any{
+		"id":       id,
+		"rawId":    id,
+		"type":     "public-key",
+		"response": response,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return data
+}
+
+// Хранилища в памяти: реализуют только методы, которые вызывают церемонии
+type memoryPasskeys struct {
+	domain.PasskeyRepository
+	passkeys []domain.Passkey
+}
+
+func (r *memoryPasskeys) CreatePasskey(_ context.Context, passkey domain.Passkey) (int, error) {
+	passkey.ID = len(r.passkeys) + 1
+	r.passkeys = append(r.passkeys, passkey)
+	return passkey.ID, nil
+}
+
+func (r *memoryPasskeys) GetPasskeys(_ context.Context, userId int) ([]domain.Passkey, error) {
+	var passkeys []domain.Passkey
+	for _, passkey := range r.passkeys {
+		if passkey.UserID == userId {
+			passkeys = append(passkeys, passkey)
+		}
+	}
+	return passkeys, nil
+}
+
+func (r *memoryPasskeys) UpdatePasskeyCredential(_ context.Context, id int, credential []byte) error {
+	for i := range r.passkeys {
+		if r.passkeys[i].ID == id {
+			r.passkeys[i].Credential = credential
+			return nil
+		}
+	}
+	return errors.New("passkey not found")
+}
+
+type memoryUsers struct {
+	domain.AuthorizationRepository
+	refreshTokens []domain.RefreshToken
+}
+
+func (r *memoryUsers) GetUserById(_ context.Context, id int) (domain.User, error) {
+	return domain.User{ID: id, Email: "user" + strconv.Itoa(id) + "@example.com"}, nil
+}
+
+func (r *memoryUsers) ClearGuest(context.Context, int) error {
+	return nil
+}
+
+func (r *memoryUsers) CreateToken(_ context.Context, token domain.RefreshToken) error {
+	r.refreshTokens = append(r.refreshTokens, token)
+	return nil
+}
+
+type noRoles struct{ domain.RoleRepository }
+
+func (noRoles) GetUserRoles(context.Context, int) ([]string, error) { return nil, nil }
+
+type noDeletion struct{ domain.AccountRepository }
+
+func (noDeletion) CancelAccountDeletion(context.Context, int) (bool, error) { return false, nil }
+
+func newTestPasskeyService(t *testing.T) (*PasskeyService, *memoryPasskeys) {
+	t.Helper()
+
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: redisServer.Addr()})
+	t.Cleanup(func() { _ = redisClient.Close() })
+
+	webAuthn, err := NewWebAuthn(WebAuthnConfig{RPID: testRPID, RPDisplayName: "SeeThisGame", RPOrigins: []string{testOrigin}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := NewJWTKeySet(JWTConfig{Issuer: "test", AllowEphemeralKey: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := &memoryUsers{}
+	passkeys := &memoryPasskeys{}
+	authService := &AuthService{repo: users, roleRepo: noRoles{}, accountRepo: noDeletion{}, keys: keys, redis: redisClient}
+	return NewPasskeyService(passkeys, users, authService, webAuthn, redisClient), passkeys
+}
+
+// registerPasskey проводит регистрацию ключа пользователю userId
+func registerPasskey(t *testing.T, service *PasskeyService, authenticator *softAuthenticator, userId int) domain.Passkey {
+	t.Helper()
+	ctx := context.Background()
+
+	ceremony, err := service.BeginPasskeyRegistration(ctx, userId)
+	if err != nil {
+		t.Fatalf("BeginPasskeyRegistration: %v", err)
+	}
+	response := authenticator.create(t, ceremony.Options.(*protocol.CredentialCreation))
+
+	passkey, err := service.FinishPasskeyRegistration(ctx, userId, ceremony.CeremonyID, "test key", response)
+	if err != nil {
+		t.Fatalf("FinishPasskeyRegistration: %v", err)
+	}
+	return passkey
+}
+
+func beginLogin(t *testing.T, service *PasskeyService) (string, *protocol.CredentialAssertion) {
+	t.Helper()
+	ceremony, err := service.BeginPasskeyLogin(context.Background())
+	if err != nil {
+		t.Fatalf("BeginPasskeyLogin: %v", err)
+	}
+	return ceremony.CeremonyID, ceremony.Options.(*protocol.CredentialAssertion)
+}
+
+func TestPasskeyRegistrationAndLogin(t *testing.T) {
+	service, passkeys := newTestPasskeyService(t)
+	authenticator := newSoftAuthenticator(t)
+
+	passkey := registerPasskey(t, service, authenticator, 42)
+	if passkey.UserID != 42 || !bytes.Equal(passkey.CredentialID, authenticator.credentialId) {
+		t.Fatalf("unexpected passkey: %+v", passkey)
+	}
+
+	ceremonyId, options := beginLogin(t, service)
+	authenticator.signCount = 1
+	tokens, err := service.FinishPasskeyLogin(context.Background(), ceremonyId, authenticator.get(t, options), domain.DeviceInfo{})
+	if err != nil {
+		t.Fatalf("FinishPasskeyLogin: %v", err)
+	}
+	if tokens.AccessToken == "" || tokens.RefreshToken == "" {
+		t.Fatal("tokens are empty")
+	}
+
+	claims, err := service.authService.ParseToken(context.Background(), tokens.AccessToken)
+	if err != nil {
+		t.Fatalf("ParseToken: %v", err)
+	}
+	if claims.UserID != 42 {
+		t.Fatalf("access token for user %d, want 42", claims.UserID)
+	}
+
+	// Новое значение счетчика сохранено вместе с ключом
+	var stored struct {
+		Authenticator struct {
+			SignCount uint32 `json:"signCount"`
+		} `json:"authenticator"`
+	}
+	if err := json.Unmarshal(passkeys.passkeys[0].Credential, &stored); err != nil {
+		t.Fatal(err)
+	}
+	if stored.Authenticator.SignCount != 1 {
+		t.Fatalf("stored sign count %d, want 1", stored.Authenticator.SignCount)
+	}
+}
+
+func TestPasskeyRegistrationForeignCeremony(t *testing.T) {
+	service, _ := newTestPasskeyService(t)
+	authenticator := newSoftAuthenticator(t)
+
+	ceremony, err := service.BeginPasskeyRegistration(context.Background(), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response := authenticator.create(t, ceremony.Options.(*protocol.CredentialCreation))
+
+	// Церемонию начал один пользователь, а завершить пытается другой
+	_, err = service.FinishPasskeyRegistration(context.Background(), 2, ceremony.CeremonyID, "", response)
+	if !errors.Is(err, domain.ErrInvalidPasskey) {
+		t.Fatalf("got %v, want ErrInvalidPasskey", err)
+	}
+}
+
+func TestPasskeyLoginReplayedChallenge(t *testing.T) {
+	service, _ := newTestPasskeyService(t)
+	authenticator := newSoftAuthenticator(t)
+	registerPasskey(t, service, authenticator, 7)
+
+	ceremonyId, options := beginLogin(t, service)
+	authenticator.signCount = 1
+	response := authenticator.get(t, options)
+	if _, err := service.FinishPasskeyLogin(context.Background(), ceremonyId, response, domain.DeviceInfo{}); err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+
+	// Та же церемония второй раз: challenge одноразовый
+	_, err := service.FinishPasskeyLogin(context.Background(), ceremonyId, response, domain.DeviceInfo{})
+	if !errors.Is(err, domain.ErrInvalidPasskey) {
+		t.Fatalf("replayed ceremony: got %v, want ErrInvalidPasskey", err)
+	}
+
+	// Перехваченный ответ к новой церемонии: подписан чужой challenge
+	newCeremonyId, _ := beginLogin(t, service)
+	_, err = service.FinishPasskeyLogin(context.Background(), newCeremonyId, response, domain.DeviceInfo{})
+	if !errors.Is(err, domain.ErrInvalidPasskey) {
+		t.Fatalf("replayed assertion: got %v, want ErrInvalidPasskey", err)
+	}
+}
+
+func TestPasskeyLoginSignCountRegression(t *testing.T) {
+	service, passkeys := newTestPasskeyService(t)
+	authenticator := newSoftAuthenticator(t)
+	registerPasskey(t, service, authenticator, 7)
+
+	ceremonyId, options := beginLogin(t, service)
+	authenticator.signCount = 5
+	if _, err := service.FinishPasskeyLogin(context.Background(), ceremonyId, authenticator.get(t, options), domain.DeviceInfo{}); err != nil {
+		t.Fatalf("first login: %v", err)
+	}
+	stored := passkeys.passkeys[0].Credential
+
+	// Копия ключа с отставшим счетчиком
+	for _, signCount := range []uint32{5, 3} {
+		ceremonyId, options = beginLogin(t, service)
+		authenticator.signCount = signCount
+		_, err := service.FinishPasskeyLogin(context.Background(), ceremonyId, authenticator.get(t, options), domain.DeviceInfo{})
+		if !errors.Is(err, domain.ErrInvalidPasskey) {
+			t.Fatalf("sign count %d: got %v, want ErrInvalidPasskey", signCount, err)
+		}
+	}
+
+	if !bytes.Equal(passkeys.passkeys[0].Credential, stored) {
+		t.Fatal("credential updated after sign count regression")
+	}
+}
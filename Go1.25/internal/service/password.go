@@ -0,0 +1,109 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"golang.org/x/crypto/argon2"
+)
+
+// Параметры Argon2id (рекомендации OWASP). Кодируются в сам хеш,
+// поэтому их можно менять — старые хеши обновятся при следующем входе.
+const (
+	argon2Time    uint32 = 2
+	argon2Memory  uint32 = 19 * 1024
+	argon2Threads uint8  = 1
+	argon2KeyLen  uint32 = 32
+	argon2SaltLen        = 16
+
+	// legacySalt — статическая соль старых SHA-1 хешей. Нужна только для
+	// проверки паролей, которые ещё не были перехешированы.
+	legacySalt = "asdagedrhftyki518sadf5as8"
+)
+
+var errInvalidPasswordHash = errors.New("invalid password hash format")
+
+// dummyPasswordHash используется при входе с несуществующим email,
+// чтобы время ответа совпадало со временем проверки настоящего пароля.
+var dummyPasswordHash, _ = hashPassword("dummy-password")
+
+// hashPassword возвращает Argon2id хеш в формате PHC:
+// $argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, argon2SaltLen)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+
+	key := argon2.IDKey([]byte(password), salt, argon2Time, argon2Memory, argon2Threads, argon2KeyLen)
+
+	return fmt.Sprintf("$argon2id$v=%d$m=%d,t=%d,p=%d$%s$%s",
+		argon2.Version, argon2Memory, argon2Time, argon2Threads,
+		base64.RawStdEncoding.EncodeToString(salt),
+		base64.RawStdEncoding.EncodeToString(key),
+	), nil
+}
+
+// verifyPassword сравнивает пароль с сохраненным хешем.
+// needsRehash = true, если хеш устарел (SHA-1 или другие параметры Argon2id).
+func verifyPassword(password, encodedHash string) (ok bool, needsRehash bool, err error) {
+	if !strings.HasPrefix(encodedHash, "$argon2id$") {
+		return verifyLegacyPassword(password, encodedHash), true, nil
+	}
+
+	parts := strings.Split(encodedHash, "$")
+	if len(parts) != 6 {
+		return false, false, errInvalidPasswordHash
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil {
+		return false, false, errInvalidPasswordHash
+	}
+	if version != argon2.Version {
+		return false, false, errInvalidPasswordHash
+	}
+
+	var memory, time uint32
+	var threads uint8
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &memory, &time, &threads); err != nil {
+		return false, false, errInvalidPasswordHash
+	}
+
+	salt, err := base64.RawStdEncoding.DecodeString(parts[4])
+	if err != nil {
+		return false, false, errInvalidPasswordHash
+	}
+	expected, err := base64.RawStdEncoding.DecodeString(parts[5])
+	if err != nil {
+		return false, false, errInvalidPasswordHash
+	}
+
+	actual := argon2.IDKey([]byte(password), salt, time, memory, threads, uint32(len(expected)))
+	if subtle.ConstantTimeCompare(actual, expected) != 1 {
+		return false, false, nil
+	}
+
+	needsRehash = memory != argon2Memory || time != argon2Time || threads != argon2Threads ||
+		uint32(len(expected)) != argon2KeyLen || len(salt) != argon2SaltLen
+
+	return true, needsRehash, nil
+}
+
+// verifyLegacyPassword проверяет старый формат: hex(salt || sha1(password)).
+func verifyLegacyPassword(password, encodedHash string) bool {
+	if encodedHash == "" {
+		return false
+	}
+
+	hash := sha1.New()
+	hash.Write([]byte(password))
+	legacy := fmt.Sprintf("%x", hash.Sum([]byte(legacySalt)))
+
+	return subtle.ConstantTimeCompare([]byte(legacy), []byte(encodedHash)) == 1
+}
@@ -0,0 +1,156 @@
+package service
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/ArtemChadaev/SeeThisGame/internal/logger"
+	"github.com/redis/go-redis/v9"
+	"github.com/spf13/viper"
+)
+
+const (
+	// passwordResetTTL — срок жизни ссылки сброса пароля
+	passwordResetTTL = time.Hour
+
+	// passwordResetCooldown — не чаще одного письма в минуту на пользователя
+	passwordResetCooldown = time.Minute
+)
+
+type PasswordService struct {
+	repo         domain.AuthorizationRepository
+	apiTokenRepo domain.APITokenRepository // Сброс пароля отзывает персональные токены
+	authService  *AuthService
+	mailer       domain.Mailer
+	tokens       *actionTokens
+	redis        *redis.Client
+	resetURL     string
+}
+
+func NewPasswordService(repo domain.AuthorizationRepository, apiTokenRepo domain.APITokenRepository, authService *AuthService, mailer domain.Mailer, tokens *actionTokens, redis *redis.Client) *PasswordService {
+	return &PasswordService{
+		repo:         repo,
+		apiTokenRepo: apiTokenRepo,
+		authService:  authService,
+		mailer:       mailer,
+		tokens:       tokens,
+		redis:        redis,
+		resetURL:     viper.GetString("mail.resetPasswordURL"),
+	}
+}
+
+// RequestPasswordReset отправляет письмо со ссылкой сброса.
+// Для неизвестного email ничего не делает и не возвращает ошибку, чтобы не раскрывать наличие аккаунта.
+func (s *PasswordService) RequestPasswordReset(ctx context.Context, email string) error {
+	ctx, span := tracer.Start(ctx, "PasswordService.RequestPasswordReset")
+	defer span.End()
+
+	user, err := s.repo.GetUserByEmail(ctx, email)
+	if err != nil {
+		return nil
+	}
+
+	key := "password_reset_cooldown:" + strconv.Itoa(user.ID)
+	allowed, err := s.redis.SetNX(ctx, key, 1, passwordResetCooldown).Result()
+	if err != nil {
+		return domain.NewInternalServerError(err)
+	}
+	if !allowed {
+		return nil
+	}
+
+	token, err := s.tokens.Issue(ctx, actionPasswordReset, user.ID, passwordResetTTL)
+	if err != nil {
+		return domain.NewInternalServerError(err)
+	}
+
+	link := s.resetURL + "?token=" + url.QueryEscape(token)
+	msg := domain.MailMessage{
+		To:      user.Email,
+		Subject: "Сброс пароля в SeeThisGame",
+		Text: fmt.Sprintf("Чтобы задать новый пароль, перейдите по ссылке:\n\n%s\n\n"+
+			"Ссылка действительна %d минут и сработает один раз. Если вы не запрашивали сброс, просто проигнорируйте это письмо.\n",
+			link, int(passwordResetTTL.Minutes())),
+	}
+	if err := s.mailer.Send(msg); err != nil {
+		s.redis.Del(ctx, key)
+		return domain.NewInternalServerError(err)
+	}
+
+	return nil
+}
+
+// ResetPassword задает новый пароль по токену из письма и завершает все сессии пользователя.
+func (s *PasswordService) ResetPassword(ctx context.Context, token, newPassword string) error {
+	ctx, span := tracer.Start(ctx, "PasswordService.ResetPassword")
+	defer span.End()
+
+	userId, err := s.tokens.Consume(ctx, actionPasswordReset, token)
+	if err != nil {
+		return err
+	}
+
+	if err := s.setPassword(ctx, userId, newPassword); err != nil {
+		return err
+	}
+
+	// Владелец подтвердил почту — снимаем блокировку входа после перебора
+	if email, err := s.repo.GetUserEmailFromId(ctx, userId); err == nil && email != "" {
+		s.authService.resetLoginFailures(ctx, email)
+	}
+
+	// Пароль могли сбросить из-за взлома: персональные токены, выпущенные взломщиком, тоже отзываются
+	revoked, err := s.apiTokenRepo.DeleteAllUserAPITokens(ctx, userId)
+	if err != nil {
+		return domain.NewInternalServerError(err)
+	}
+	if revoked > 0 {
+		logger.FromContext(ctx).Infof("password reset revoked %d api tokens of user %d", revoked, userId)
+	}
+
+	return s.authService.UnAuthorizeAll(ctx, userId)
+}
+
+// ChangePassword меняет пароль авторизованного пользователя после проверки текущего.
+// Все сессии завершаются, а для текущего устройства выдаются новые токены.
+func (s *PasswordService) ChangePassword(ctx context.Context, userId int, currentPassword, newPassword string, device domain.DeviceInfo) (domain.ResponseTokens, error) {
+	ctx, span := tracer.Start(ctx, "PasswordService.ChangePassword")
+	defer span.End()
+
+	user, err := s.repo.GetUserById(ctx, userId)
+	if err != nil {
+		return domain.ResponseTokens{}, domain.ErrUserNotFound
+	}
+
+	if _, err := s.authService.checkCredentials(ctx, user.Email, currentPassword); err != nil {
+		return domain.ResponseTokens{}, err
+	}
+
+	if err := s.setPassword(ctx, userId, newPassword); err != nil {
+		return domain.ResponseTokens{}, err
+	}
+
+	if err := s.authService.UnAuthorizeAll(ctx, userId); err != nil {
+		return domain.ResponseTokens{}, err
+	}
+
+	return s.authService.createTokens(ctx, userId, device)
+}
+
+func (s *PasswordService) setPassword(ctx context.Context, userId int, password string) error {
+	hash, err := hashPassword(password)
+	if err != nil {
+		return domain.NewInternalServerError(err)
+	}
+
+	if err := s.repo.UpdateUserPassword(ctx, domain.User{ID: userId, Password: hash}); err != nil {
+		return domain.NewInternalServerError(err)
+	}
+
+	logger.FromContext(ctx).Infof("password changed for user %d", userId)
+	return nil
+}
@@ -0,0 +1,200 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/ArtemChadaev/SeeThisGame/internal/logger"
+)
+
+// rolePermissionsTTL — как долго права ролей берутся из памяти. Права меняются только
+// миграциями, а проверка идет на каждый запрос к защищенным маршрутам.
+const rolePermissionsTTL = time.Minute
+
+type RoleService struct {
+	repo        domain.RoleRepository
+	authRepo    domain.AuthorizationRepository
+	authService *AuthService
+
+	mu          sync.RWMutex
+	permissions map[string]map[string]bool // роль → права
+	loadedAt    time.Time
+}
+
+func NewRoleService(repo domain.RoleRepository, authRepo domain.AuthorizationRepository, authService *AuthService) *RoleService {
+	return &RoleService{
+		repo:        repo,
+		authRepo:    authRepo,
+		authService: authService,
+	}
+}
+
+func (s *RoleService) GetRoles(ctx context.Context) ([]domain.Role, error) {
+	ctx, span := tracer.Start(ctx, "RoleService.GetRoles")
+	defer span.End()
+
+	roles, err := s.repo.GetRoles(ctx)
+	if err != nil {
+		return nil, domain.NewInternalServerError(err)
+	}
+	return roles, nil
+}
+
+func (s *RoleService) GetUserRoles(ctx context.Context, userId int) ([]string, error) {
+	ctx, span := tracer.Start(ctx, "RoleService.GetUserRoles")
+	defer span.End()
+
+	if _, err := s.authRepo.GetUserById(ctx, userId); err != nil {
+		return nil, domain.ErrUserNotFound
+	}
+
+	roles, err := s.repo.GetUserRoles(ctx, userId)
+	if err != nil {
+		return nil, domain.NewInternalServerError(err)
+	}
+	if roles == nil {
+		roles = []string{}
+	}
+	return roles, nil
+}
+
+// GrantRole выдает роль пользователю. Повторная выдача ничего не меняет.
+func (s *RoleService) GrantRole(ctx context.Context, actorId, userId int, role string) error {
+	ctx, span := tracer.Start(ctx, "RoleService.GrantRole")
+	defer span.End()
+
+	if err := s.checkRoleChange(ctx, actorId, userId, role); err != nil {
+		return err
+	}
+
+	added, err := s.repo.AddUserRole(ctx, userId, role, actorId)
+	if err != nil {
+		return domain.NewInternalServerError(err)
+	}
+	if !added {
+		return nil
+	}
+
+	s.rolesChanged(ctx, actorId, userId, role, domain.SecurityEventRoleGranted)
+	return nil
+}
+
+// RevokeRole снимает роль. Уже выданные access токены с этой ролью перестают работать сразу.
+func (s *RoleService) RevokeRole(ctx context.Context, actorId, userId int, role string) error {
+	ctx, span := tracer.Start(ctx, "RoleService.RevokeRole")
+	defer span.End()
+
+	if err := s.checkRoleChange(ctx, actorId, userId, role); err != nil {
+		return err
+	}
+
+	deleted, err := s.repo.DeleteUserRole(ctx, userId, role)
+	if err != nil {
+		return domain.NewInternalServerError(err)
+	}
+	if !deleted {
+		return domain.ErrRoleNotFound
+	}
+
+	s.rolesChanged(ctx, actorId, userId, role, domain.SecurityEventRoleRevoked)
+	return nil
+}
+
+// HasPermission проверяет, дает ли хотя бы одна из ролей право permission.
+func (s *RoleService) HasPermission(ctx context.Context, roles []string, permission string) (bool, error) {
+	ctx, span := tracer.Start(ctx, "RoleService.HasPermission")
+	defer span.End()
+
+	if len(roles) == 0 {
+		return false, nil
+	}
+
+	permissions, err := s.rolePermissions(ctx)
+	if err != nil {
+		return false, domain.NewInternalServerError(err)
+	}
+
+	for _, role := range roles {
+		if permissions[role][permission] {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// checkRoleChange — свои роли менять нельзя (иначе администратор может случайно
+// лишить систему последнего админа), роль и пользователь должны существовать.
+func (s *RoleService) checkRoleChange(ctx context.Context, actorId, userId int, role string) error {
+	if actorId == userId {
+		return domain.ErrOwnRoles
+	}
+
+	permissions, err := s.rolePermissions(ctx)
+	if err != nil {
+		return domain.NewInternalServerError(err)
+	}
+	if _, ok := permissions[role]; !ok {
+		return domain.ErrRoleNotFound
+	}
+
+	if _, err := s.authRepo.GetUserById(ctx, userId); err != nil {
+		return domain.ErrUserNotFound
+	}
+	return nil
+}
+
+// rolesChanged отзывает старые access токены пользователя и пишет событие в журнал.
+// Роль в базе уже изменена, поэтому ошибки здесь только логируем.
+func (s *RoleService) rolesChanged(ctx context.Context, actorId, userId int, role, eventType string) {
+	if err := s.authService.revokeUserAccess(ctx, userId); err != nil {
+		logger.FromContext(ctx).Errorf("failed to revoke access tokens of user %d after role change: %v", userId, err)
+	}
+
+	event := domain.SecurityEvent{
+		UserID: userId,
+		Type:   eventType,
+		Details: map[string]string{
+			"role":     role,
+			"actor_id": strconv.Itoa(actorId),
+		},
+	}
+	if err := s.authRepo.CreateSecurityEvent(ctx, event); err != nil {
+		logger.FromContext(ctx).Errorf("failed to record security event for user %d: %v", userId, err)
+	}
+
+	logger.FromContext(ctx).Infof("%s: role %q, user %d, by user %d", eventType, role, userId, actorId)
+}
+
+// rolePermissions возвращает права ролей из памяти, перечитывая их раз в rolePermissionsTTL.
+func (s *RoleService) rolePermissions(ctx context.Context) (map[string]map[string]bool, error) {
+	s.mu.RLock()
+	if s.permissions != nil && time.Since(s.loadedAt) < rolePermissionsTTL {
+		permissions := s.permissions
+		s.mu.RUnlock()
+		return permissions, nil
+	}
+	s.mu.RUnlock()
+
+	roles, err := s.repo.GetRoles(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions := make(map[string]map[string]bool, len(roles))
+	for _, role := range roles {
+		permissions[role.Name] = make(map[string]bool, len(role.Permissions))
+		for _, permission := range role.Permissions {
+			permissions[role.Name][permission] = true
+		}
+	}
+
+	s.mu.Lock()
+	s.permissions = permissions
+	s.loadedAt = time.Now()
+	s.mu.Unlock()
+
+	return permissions, nil
+}
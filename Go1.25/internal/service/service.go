@@ -3,25 +3,59 @@ package service
 import (
 	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
 	"github.com/ArtemChadaev/SeeThisGame/internal/repository"
+	"github.com/go-webauthn/webauthn/webauthn"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/otel"
 )
 
+// tracer создает спаны методов сервисов. Спаны запросов к Postgres, Redis и внешним
+// HTTP API становятся их дочерними через ctx.
+var tracer = otel.Tracer("github.com/ArtemChadaev/SeeThisGame/internal/service")
+
 // Service объединяет в себе все интерфейсы сервисов из ядра (domain)
 type Service struct {
 	domain.AuthorizationService
 	domain.UserSettingsService
 	domain.OAuthService
+	domain.EmailVerificationService
+	domain.PasswordService
+	domain.MFAService
+	domain.PasskeyService
+	domain.IdentityService
+	domain.RoleService
+	domain.AccountService
+	domain.APITokenService
+	domain.HealthService
 }
 
-func NewService(repos *repository.Repository, redis *redis.Client) *Service {
+func NewService(repos *repository.Repository, redis *redis.Client, jwtKeys *JWTKeySet, mailer domain.Mailer, webAuthn *webauthn.WebAuthn, oauthProviders *OAuthProviders) *Service {
 	// Инициализируем конкретные реализации логики
 	userSettingsService := NewUserSettingsService(repos.UserSettingsRepository, redis)
-	authService := NewAuthService(repos.AuthorizationRepository, userSettingsService)
-	oauthService := NewOAuthService(repos.AuthorizationRepository, authService)
+	authService := NewAuthService(repos.AuthorizationRepository, repos.MFARepository, repos.RoleRepository, repos.AccountRepository, userSettingsService, jwtKeys, redis)
+	oauthService := NewOAuthService(repos.AuthorizationRepository, repos.IdentityRepository, authService, oauthProviders, redis)
+	actionTokens := newActionTokens(redis)
+	emailVerificationService := NewEmailVerificationService(repos.AuthorizationRepository, mailer, actionTokens, redis)
+	passwordService := NewPasswordService(repos.AuthorizationRepository, repos.APITokenRepository, authService, mailer, actionTokens, redis)
+	mfaService := NewMFAService(repos.MFARepository, repos.AuthorizationRepository, authService, redis)
+	identityService := NewIdentityService(repos.IdentityRepository, repos.AuthorizationRepository, repos.PasskeyRepository)
+	roleService := NewRoleService(repos.RoleRepository, repos.AuthorizationRepository, authService)
+	accountService := NewAccountService(repos.AccountRepository, repos.AuthorizationRepository, repos.APITokenRepository, authService, oauthService, mfaService)
+	apiTokenService := NewAPITokenService(repos.APITokenRepository, repos.RoleRepository)
+	healthService := NewHealthService(repos.HealthRepository, redis)
+	passkeyService := NewPasskeyService(repos.PasskeyRepository, repos.AuthorizationRepository, authService, webAuthn, redis)
 
 	return &Service{
-		AuthorizationService: authService,
-		UserSettingsService:  userSettingsService,
-		OAuthService:         oauthService,
+		AuthorizationService:     authService,
+		UserSettingsService:      userSettingsService,
+		OAuthService:             oauthService,
+		EmailVerificationService: emailVerificationService,
+		PasswordService:          passwordService,
+		MFAService:               mfaService,
+		PasskeyService:           passkeyService,
+		IdentityService:          identityService,
+		RoleService:              roleService,
+		AccountService:           accountService,
+		APITokenService:          apiTokenService,
+		HealthService:            healthService,
 	}
 }
@@ -0,0 +1,100 @@
+package service
+
+import (
+	"context"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+)
+
+// maxDeviceInfoLength — размер колонок name_device/device_info в user_refresh_tokens
+const maxDeviceInfoLength = 255
+
+// GetSessions возвращает активные входы пользователя (по одному на семейство refresh токенов).
+func (s *AuthService) GetSessions(ctx context.Context, userId int) ([]domain.Session, error) {
+	ctx, span := tracer.Start(ctx, "AuthService.GetSessions")
+	defer span.End()
+
+	tokens, err := s.repo.GetRefreshTokens(ctx, userId)
+	if err != nil {
+		return nil, domain.NewInternalServerError(err)
+	}
+
+	sessions := make([]domain.Session, 0, len(tokens))
+	for _, token := range tokens {
+		sessions = append(sessions, domain.Session{
+			ID:         token.FamilyID,
+			NameDevice: token.NameDevice,
+			DeviceInfo: token.DeviceInfo,
+			IP:         token.IPAddress,
+			CreatedAt:  token.CreatedAt,
+			LastUsedAt: token.LastUsedAt,
+			ExpiresAt:  token.ExpiresAt,
+		})
+	}
+
+	return sessions, nil
+}
+
+// RenameSession задает пользовательское имя устройства.
+func (s *AuthService) RenameSession(ctx context.Context, userId int, sessionId, name string) error {
+	ctx, span := tracer.Start(ctx, "AuthService.RenameSession")
+	defer span.End()
+
+	if err := s.checkSessionOwner(ctx, userId, sessionId); err != nil {
+		return err
+	}
+
+	if err := s.repo.UpdateRefreshTokenName(ctx, sessionId, truncate(name, maxDeviceInfoLength)); err != nil {
+		return domain.NewInternalServerError(err)
+	}
+	return nil
+}
+
+// DeleteSession завершает вход на устройстве (удаляет семейство refresh токенов).
+func (s *AuthService) DeleteSession(ctx context.Context, userId int, sessionId string) error {
+	ctx, span := tracer.Start(ctx, "AuthService.DeleteSession")
+	defer span.End()
+
+	if err := s.checkSessionOwner(ctx, userId, sessionId); err != nil {
+		return err
+	}
+
+	if err := s.repo.DeleteRefreshTokenFamily(ctx, sessionId); err != nil {
+		return domain.NewInternalServerError(err)
+	}
+	// Access токены этой сессии перестают работать сразу, а не через 15 минут
+	if err := s.revokeSessionAccess(ctx, sessionId); err != nil {
+		return domain.NewInternalServerError(err)
+	}
+	return nil
+}
+
+// checkSessionOwner проверяет, что сессия существует и принадлежит пользователю.
+func (s *AuthService) checkSessionOwner(ctx context.Context, userId int, sessionId string) error {
+	tokens, err := s.repo.GetRefreshTokens(ctx, userId)
+	if err != nil {
+		return domain.NewInternalServerError(err)
+	}
+
+	for _, token := range tokens {
+		if token.FamilyID == sessionId {
+			return nil
+		}
+	}
+	return domain.ErrSessionNotFound
+}
+
+// truncate обрезает строку до max байт, не разрывая UTF-8 символы.
+func truncate(value string, max int) string {
+	if len(value) <= max {
+		return value
+	}
+	cut := 0
+	for i := range value {
+		if i > max {
+			break
+		}
+		cut = i
+	}
+	return value[:cut]
+}
@@ -0,0 +1,109 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/spf13/viper"
+)
+
+const (
+	// telegramClockSkew — допустимое расхождение часов, если auth_date немного в будущем
+	telegramClockSkew = time.Minute
+
+	telegramAuthKey = "telegram_auth:"
+)
+
+// telegramAuthMaxAge — сколько после auth_date данные виджета считаются свежими
+func telegramAuthMaxAge() time.Duration {
+	maxAge := viper.GetDuration("telegram.authMaxAge")
+	if maxAge <= 0 {
+		return 24 * time.Hour
+	}
+	return maxAge
+}
+
+// HandleTelegram входит по данным Telegram Login Widget. Подпись проверяется без
+// обращения к Telegram: hash = HMAC-SHA256(data_check_string, SHA256(bot_token)).
+// Одни и те же данные принимаются только один раз.
+func (s *OAuthService) HandleTelegram(ctx context.Context, data map[string]string, device domain.DeviceInfo) (domain.SignInResult, error) {
+	ctx, span := tracer.Start(ctx, "OAuthService.HandleTelegram")
+	defer span.End()
+
+	if s.telegramBotToken == "" {
+		return domain.SignInResult{}, domain.ErrOAuthProviderNotFound
+	}
+
+	if !verifyTelegramHash(data, s.telegramBotToken) {
+		return domain.SignInResult{}, domain.ErrInvalidTelegramAuth
+	}
+
+	authDate, err := strconv.ParseInt(data["auth_date"], 10, 64)
+	if err != nil {
+		return domain.SignInResult{}, domain.ErrInvalidTelegramAuth
+	}
+	age := time.Since(time.Unix(authDate, 0))
+	if age > s.telegramAuthMaxAge || age < -telegramClockSkew {
+		return domain.SignInResult{}, domain.ErrInvalidTelegramAuth
+	}
+
+	if data["id"] == "" {
+		return domain.SignInResult{}, domain.ErrInvalidTelegramAuth
+	}
+
+	// Перехваченные данные нельзя использовать повторно, пока они не устарели
+	fresh, err := s.redis.SetNX(ctx, telegramAuthKey+data["hash"], 1, s.telegramAuthMaxAge).Result()
+	if err != nil {
+		return domain.SignInResult{}, domain.NewInternalServerError(err)
+	}
+	if !fresh {
+		return domain.SignInResult{}, domain.ErrInvalidTelegramAuth
+	}
+
+	name := strings.TrimSpace(data["first_name"] + " " + data["last_name"])
+	if name == "" {
+		name = data["username"]
+	}
+
+	return s.authenticateOAuthUser(ctx, domain.OAuthUserInfo{
+		Provider: domain.OAuthProviderTelegram,
+		ID:       data["id"],
+		Name:     name,
+		Picture:  data["photo_url"],
+	}, device)
+}
+
+// verifyTelegramHash проверяет подпись по всем полученным полям, кроме hash,
+// отсортированным по ключу и склеенным через \n в виде key=value.
+func verifyTelegramHash(data map[string]string, botToken string) bool {
+	expected, err := hex.DecodeString(data["hash"])
+	if err != nil || len(expected) == 0 {
+		return false
+	}
+
+	keys := make([]string, 0, len(data))
+	for key := range data {
+		if key != "hash" {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	lines := make([]string, 0, len(keys))
+	for _, key := range keys {
+		lines = append(lines, key+"="+data[key])
+	}
+
+	secret := sha256.Sum256([]byte(botToken))
+	mac := hmac.New(sha256.New, secret[:])
+	mac.Write([]byte(strings.Join(lines, "\n")))
+
+	return hmac.Equal(mac.Sum(nil), expected)
+}
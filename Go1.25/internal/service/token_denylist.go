@@ -0,0 +1,77 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/redis/go-redis/v9"
+)
+
+// Ключи denylist в Redis. Живут не дольше access токена — после этого токен и так невалиден.
+const (
+	revokedAccessTokenKey = "revoked_access:"  // + jti
+	revokedSessionKey     = "revoked_session:" // + sid (семейство refresh токенов)
+	revokedUserKey        = "revoked_user:"    // + user id, значение — время отзыва (unix, секунды)
+)
+
+// revokeAccessToken отзывает один access токен до конца его жизни.
+func (s *AuthService) revokeAccessToken(ctx context.Context, claims domain.AccessTokenClaims) error {
+	ttl := time.Until(claims.ExpiresAt)
+	if claims.TokenID == "" || ttl <= 0 {
+		return nil
+	}
+	return s.redis.Set(ctx, revokedAccessTokenKey+claims.TokenID, 1, ttl).Err()
+}
+
+// revokeSessionAccess отзывает все выданные access токены сессии.
+func (s *AuthService) revokeSessionAccess(ctx context.Context, sessionId string) error {
+	return s.redis.Set(ctx, revokedSessionKey+sessionId, 1, accessTokenTTL).Err()
+}
+
+// revokeSessionsAccess отзывает access токены сразу нескольких сессий.
+// Каждый access токен несет sid, поэтому отзыв всех сессий пользователя отзывает и все его токены,
+// а токены, выданные после этого (например, при смене пароля), продолжают работать.
+func (s *AuthService) revokeSessionsAccess(ctx context.Context, sessionIds []string) error {
+	if len(sessionIds) == 0 {
+		return nil
+	}
+
+	pipe := s.redis.Pipeline()
+	for _, sessionId := range sessionIds {
+		pipe.Set(ctx, revokedSessionKey+sessionId, 1, accessTokenTTL)
+	}
+	_, err := pipe.Exec(ctx)
+	return err
+}
+
+// revokeUserAccess отзывает все access токены пользователя, выданные до этого момента.
+// Сессии остаются: после refresh клиент получит токен с актуальными ролями.
+func (s *AuthService) revokeUserAccess(ctx context.Context, userId int) error {
+	key := revokedUserKey + strconv.Itoa(userId)
+	return s.redis.Set(ctx, key, time.Now().Unix(), accessTokenTTL).Err()
+}
+
+// isAccessRevoked проверяет токен по всем спискам одним запросом.
+// Если Redis недоступен, отозванный токен нельзя отличить от действующего, поэтому
+// проверка не проходит (fail closed): ошибка возвращается, а сбой считает RedisHook.
+func (s *AuthService) isAccessRevoked(ctx context.Context, claims domain.AccessTokenClaims) (bool, error) {
+	pipe := s.redis.Pipeline()
+	tokenRevoked := pipe.Exists(ctx, revokedAccessTokenKey+claims.TokenID)
+	sessionRevoked := pipe.Exists(ctx, revokedSessionKey+claims.SessionID)
+	userRevokedAt := pipe.Get(ctx, revokedUserKey+strconv.Itoa(claims.UserID))
+	if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) {
+		return false, fmt.Errorf("check access token denylist: %w", err)
+	}
+
+	// iat в токене с точностью до секунды: токен, выданный в секунду отзыва, мог быть выдан
+	// и до него (со старыми ролями), поэтому отклоняется тоже — клиент просто повторит refresh
+	if revokedAt, err := userRevokedAt.Int64(); err == nil && claims.IssuedAt.Unix() <= revokedAt {
+		return true, nil
+	}
+
+	return tokenRevoked.Val() > 0 || sessionRevoked.Val() > 0, nil
+}
@@ -0,0 +1,59 @@
+package service
+
+import (
+	"context"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+func TestRevokeUserAccessBoundary(t *testing.T) {
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: redisServer.Addr()})
+	t.Cleanup(func() { _ = redisClient.Close() })
+
+	service := &AuthService{redis: redisClient}
+	ctx := context.Background()
+
+	if err := service.revokeUserAccess(ctx, 1); err != nil {
+		t.Fatal(err)
+	}
+	stored, err := redisServer.Get(revokedUserKey + "1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	revokedAt, err := strconv.ParseInt(stored, 10, 64)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Unix(revokedAt, 0)
+
+	tests := []struct {
+		name     string
+		userId   int
+		issuedAt time.Time
+		want     bool
+	}{
+		{"issued before revocation", 1, now.Add(-time.Minute), true},
+		// iat в секундах: токен той же секунды мог быть выдан до отзыва
+		{"issued in the same second", 1, now, true},
+		{"issued after revocation", 1, now.Add(time.Second), false},
+		{"other user", 2, now.Add(-time.Minute), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claims := domain.AccessTokenClaims{UserID: tt.userId, SessionID: "s", TokenID: "t", IssuedAt: tt.issuedAt}
+			revoked, err := service.isAccessRevoked(ctx, claims)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if revoked != tt.want {
+				t.Fatalf("revoked = %v, want %v", revoked, tt.want)
+			}
+		})
+	}
+}
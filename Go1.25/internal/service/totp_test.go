@@ -0,0 +1,168 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/alicebob/miniredis/v2"
+	"github.com/redis/go-redis/v9"
+)
+
+// Секрет из тестовых векторов RFC 6238 (SHA1): ASCII "12345678901234567890"
+var rfc6238Secret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+func TestTOTPCodeRFC6238Vectors(t *testing.T) {
+	// В RFC коды из 8 цифр, у нас 6 — это младшие 6 цифр того же значения
+	vectors := []struct {
+		unix int64
+		code string
+	}{
+		{59, "287082"},
+		{1111111109, "081804"},
+		{1111111111, "050471"},
+		{1234567890, "005924"},
+		{2000000000, "279037"},
+		{20000000000, "353130"},
+	}
+	for _, v := range vectors {
+		if got := totpCode([]byte("12345678901234567890"), v.unix/totpPeriod); got != v.code {
+			t.Errorf("T=%d: got %s, want %s", v.unix, got, v.code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	now := time.Unix(1111111111, 0)
+	current := now.Unix() / totpPeriod
+	key := []byte("12345678901234567890")
+
+	tests := []struct {
+		name     string
+		secret   string
+		code     string
+		wantStep int64
+		wantOK   bool
+	}{
+		{"current step", rfc6238Secret, "050471", current, true},
+		{"previous step", rfc6238Secret, totpCode(key, current-1), current - 1, true},
+		{"next step", rfc6238Secret, totpCode(key, current+1), current + 1, true},
+		{"spaces around code", rfc6238Secret, " 050471 ", current, true},
+		{"lowercase secret", "gezdgnbvgy3tqojqgezdgnbvgy3tqojq", "050471", current, true},
+		{"outside skew", rfc6238Secret, totpCode(key, current-2), 0, false},
+		{"wrong code", rfc6238Secret, "000000", 0, false},
+		{"short code", rfc6238Secret, "05047", 0, false},
+		{"invalid secret", "not base32!", "050471", 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			step, ok := validateTOTP(tt.secret, tt.code, now)
+			if ok != tt.wantOK || step != tt.wantStep {
+				t.Fatalf("got (%d, %v), want (%d, %v)", step, ok, tt.wantStep, tt.wantOK)
+			}
+		})
+	}
+}
+
+type memoryTOTP struct {
+	domain.MFARepository
+	totp domain.TOTP
+}
+
+func (r *memoryTOTP) GetTOTP(context.Context, int) (domain.TOTP, error) {
+	return r.totp, nil
+}
+
+func (r *memoryTOTP) UpdateTOTPLastStep(_ context.Context, _ int, step int64) (bool, error) {
+	if step <= r.totp.LastUsedStep {
+		return false, nil
+	}
+	r.totp.LastUsedStep = step
+	return true, nil
+}
+
+func (r *memoryTOTP) UseRecoveryCode(context.Context, int, string) (bool, error) {
+	return false, nil
+}
+
+type memorySecurityEvents struct {
+	domain.AuthorizationRepository
+	events []domain.SecurityEvent
+}
+
+func (r *memorySecurityEvents) CreateSecurityEvent(_ context.Context, event domain.SecurityEvent) error {
+	r.events = append(r.events, event)
+	return nil
+}
+
+func newTestMFAService(t *testing.T) (*MFAService, *memoryTOTP, *memorySecurityEvents) {
+	t.Helper()
+
+	redisServer := miniredis.RunT(t)
+	redisClient := redis.NewClient(&redis.Options{Addr: redisServer.Addr()})
+	t.Cleanup(func() { _ = redisClient.Close() })
+
+	secret, err := generateTOTPSecret()
+	if err != nil {
+		t.Fatal(err)
+	}
+	totp := &memoryTOTP{totp: domain.TOTP{UserID: 1, Secret: secret, Enabled: true}}
+	events := &memorySecurityEvents{}
+	return NewMFAService(totp, events, &AuthService{}, redisClient), totp, events
+}
+
+func currentTOTPCode(t *testing.T, secret string) string {
+	t.Helper()
+	key, err := totpEncoding.DecodeString(secret)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return totpCode(key, time.Now().Unix()/totpPeriod)
+}
+
+func TestVerifySecondFactorRejectsReusedCode(t *testing.T) {
+	service, totp, _ := newTestMFAService(t)
+	code := currentTOTPCode(t, totp.totp.Secret)
+
+	if err := service.verifySecondFactor(context.Background(), 1, code); err != nil {
+		t.Fatalf("first use: %v", err)
+	}
+	if err := service.verifySecondFactor(context.Background(), 1, code); !errors.Is(err, domain.ErrInvalidMFACode) {
+		t.Fatalf("second use: got %v, want ErrInvalidMFACode", err)
+	}
+}
+
+func TestCompleteSignInLocksUserAcrossChallenges(t *testing.T) {
+	service, totp, events := newTestMFAService(t)
+	ctx := context.Background()
+
+	// Каждая попытка — с новым challenge, как если бы пароль вводили заново
+	newChallenge := func(i int) string {
+		token := "challenge-" + strconv.Itoa(i)
+		if err := service.redis.Set(ctx, mfaChallengeKey+token, 1, mfaChallengeTTL).Err(); err != nil {
+			t.Fatal(err)
+		}
+		return token
+	}
+
+	for i := 0; i < loginFreeAttempts+1; i++ {
+		_, err := service.CompleteSignIn(ctx, newChallenge(i), "000000", domain.DeviceInfo{})
+		if !errors.Is(err, domain.ErrInvalidMFACode) {
+			t.Fatalf("attempt %d: got %v, want ErrInvalidMFACode", i+1, err)
+		}
+	}
+
+	// Теперь отказ даже с верным кодом
+	_, err := service.CompleteSignIn(ctx, newChallenge(100), currentTOTPCode(t, totp.totp.Secret), domain.DeviceInfo{})
+	var appErr *domain.AppError
+	if !errors.As(err, &appErr) || appErr.Code != "account_locked" {
+		t.Fatalf("after lock: got %v, want account_locked", err)
+	}
+
+	if len(events.events) != 1 || events.events[0].Type != domain.SecurityEventMFALocked {
+		t.Fatalf("security events: %+v", events.events)
+	}
+}
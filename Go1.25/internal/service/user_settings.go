@@ -3,9 +3,12 @@ package service
 import (
 	"context"
 	"errors"
+	"strconv"
 	"time"
 
 	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/ArtemChadaev/SeeThisGame/internal/logger"
+	"github.com/ArtemChadaev/SeeThisGame/internal/metrics"
 	"github.com/redis/go-redis/v9"
 	"github.com/sirupsen/logrus"
 )
@@ -19,6 +22,10 @@ const (
 
 	// dayCoins — количество монеток, выдаваемых ежедневно
 	dayCoins = 3
+
+	// userTierCacheTTL — сколько тариф пользователя хранится в Redis для лимитера запросов
+	userTierCacheTTL = time.Minute
+	userTierKey      = "user_tier:"
 )
 
 type UserSettingsService struct {
@@ -39,23 +46,37 @@ func NewUserSettingsService(repo domain.UserSettingsRepository, redis *redis.Cli
 }
 
 // CreateInitialUserSettings создает начальные настройки для нового пользователя.
-func (s *UserSettingsService) CreateInitialUserSettings(userId int, name string) error {
+// icon может быть пустым (например, аватар провайдера входа не задан).
+func (s *UserSettingsService) CreateInitialUserSettings(ctx context.Context, userId int, name, icon string) error {
+	ctx, span := tracer.Start(ctx, "UserSettingsService.CreateInitialUserSettings")
+	defer span.End()
+
 	settings := domain.UserSettings{ // Используем конкретную структуру
 		UserID:             userId,
 		Name:               name,
 		DateOfRegistration: time.Now(),
 	}
-	return s.repo.CreateUserSettings(settings)
+	// Слишком длинную ссылку не обрезаем — битый URL хуже, чем иконка по умолчанию
+	if icon != "" && len(icon) <= 255 {
+		settings.Icon = &icon
+	}
+	return s.repo.CreateUserSettings(ctx, settings)
 }
 
 // GetByUserID возвращает настройки пользователя по его ID.
-func (s *UserSettingsService) GetByUserID(userId int) (domain.UserSettings, error) {
-	return s.repo.GetUserSettings(userId)
+func (s *UserSettingsService) GetByUserID(ctx context.Context, userId int) (domain.UserSettings, error) {
+	ctx, span := tracer.Start(ctx, "UserSettingsService.GetByUserID")
+	defer span.End()
+
+	return s.repo.GetUserSettings(ctx, userId)
 }
 
 // UpdateInfo обновляет имя и иконку пользователя.
-func (s *UserSettingsService) UpdateInfo(userId int, name, icon string) error {
-	settings, err := s.repo.GetUserSettings(userId)
+func (s *UserSettingsService) UpdateInfo(ctx context.Context, userId int, name, icon string) error {
+	ctx, span := tracer.Start(ctx, "UserSettingsService.UpdateInfo")
+	defer span.End()
+
+	settings, err := s.repo.GetUserSettings(ctx, userId)
 	if err != nil {
 		return err
 	}
@@ -65,12 +86,15 @@ func (s *UserSettingsService) UpdateInfo(userId int, name, icon string) error {
 		settings.Icon = &icon
 	}
 
-	return s.repo.UpdateUserSettings(settings)
+	return s.repo.UpdateUserSettings(ctx, settings)
 }
 
 // ChangeCoins изменяет баланс монет пользователя (добавляет или списывает).
-func (s *UserSettingsService) ChangeCoins(userId, coin int) error {
-	settings, err := s.repo.GetUserSettings(userId)
+func (s *UserSettingsService) ChangeCoins(ctx context.Context, userId, coin int) error {
+	ctx, span := tracer.Start(ctx, "UserSettingsService.ChangeCoins")
+	defer span.End()
+
+	settings, err := s.repo.GetUserSettings(ctx, userId)
 	if err != nil {
 		return err // Ошибка будет обработана выше (например, UserNotFound)
 	}
@@ -80,16 +104,19 @@ func (s *UserSettingsService) ChangeCoins(userId, coin int) error {
 		return errors.New("insufficient coins") // Или domain.ErrNoCoins
 	}
 
-	return s.repo.UpdateUserCoin(userId, newBalance)
+	return s.repo.UpdateUserCoin(ctx, userId, newBalance)
 }
 
 // ActivateSubscription активирует или продлевает подписку.
-func (s *UserSettingsService) ActivateSubscription(userId, daysToAdd int, paymentToken string) error {
+func (s *UserSettingsService) ActivateSubscription(ctx context.Context, userId, daysToAdd int, paymentToken string) error {
+	ctx, span := tracer.Start(ctx, "UserSettingsService.ActivateSubscription")
+	defer span.End()
+
 	if paymentToken != mockPaymentToken {
-		return errors.New("payment failed")
+		return domain.ErrPaymentFailed
 	}
 
-	settings, err := s.repo.GetUserSettings(userId)
+	settings, err := s.repo.GetUserSettings(ctx, userId)
 	if err != nil {
 		return err
 	}
@@ -103,16 +130,59 @@ func (s *UserSettingsService) ActivateSubscription(userId, daysToAdd int, paymen
 		newExpirationDate = time.Now().AddDate(0, 0, daysToAdd)
 	}
 
-	return s.repo.BuyPaidSubscription(userId, newExpirationDate)
+	if err := s.repo.BuyPaidSubscription(ctx, userId, newExpirationDate); err != nil {
+		return err
+	}
+
+	// Новые лимиты должны действовать сразу, а не после истечения кеша
+	if err := s.redis.Del(ctx, userTierKey+strconv.Itoa(userId)).Err(); err != nil {
+		logger.FromContext(ctx).Errorf("failed to reset tier cache of user %d: %v", userId, err)
+	}
+	return nil
+}
+
+// GetTier возвращает тариф пользователя (free или paid). Лимитер спрашивает его
+// на каждый запрос, поэтому результат кешируется в Redis на userTierCacheTTL.
+func (s *UserSettingsService) GetTier(ctx context.Context, userId int) (string, error) {
+	ctx, span := tracer.Start(ctx, "UserSettingsService.GetTier")
+	defer span.End()
+
+	key := userTierKey + strconv.Itoa(userId)
+
+	if tier, err := s.redis.Get(ctx, key).Result(); err == nil {
+		return tier, nil
+	}
+
+	settings, err := s.repo.GetUserSettings(ctx, userId)
+	if err != nil {
+		return "", err
+	}
+
+	tier, ttl := domain.TierFree, userTierCacheTTL
+	// Подписку может еще не снять фоновая задача, поэтому смотрим и на дату окончания
+	if settings.PaidSubscription && (settings.DateOfPaidSubscription == nil || settings.DateOfPaidSubscription.After(time.Now())) {
+		tier = domain.TierPaid
+		if settings.DateOfPaidSubscription != nil {
+			ttl = min(ttl, time.Until(*settings.DateOfPaidSubscription))
+		}
+	}
+
+	if err := s.redis.Set(ctx, key, tier, ttl).Err(); err != nil {
+		logger.FromContext(ctx).Errorf("failed to cache tier of user %d: %v", userId, err)
+	}
+	return tier, nil
 }
 
 // GetGrantDailyReward выдает ежедневную награду, используя Redis для контроля.
-func (s *UserSettingsService) GetGrantDailyReward(userId int) error {
+func (s *UserSettingsService) GetGrantDailyReward(ctx context.Context, userId int) error {
+	ctx, span := tracer.Start(ctx, "UserSettingsService.GetGrantDailyReward")
+	defer span.End()
+
 	// Ключ уникален для каждого дня
 	key := "daily_rewards:" + time.Now().UTC().Format("2006-01-02")
 
 	// Атомарно проверяем и добавляем пользователя в Redis Set
-	added, err := s.redis.SAdd(context.Background(), key, userId).Result()
+	added, err := s.redis.SAdd(ctx, key, userId).Result()
 	if err != nil {
 		return err
 	}
@@ -122,14 +192,15 @@ func (s *UserSettingsService) GetGrantDailyReward(userId int) error {
 	}
 
 	// Устанавливаем TTL для автоматической очистки ключа
-	s.redis.Expire(context.Background(), key, 24*time.Hour)
+	s.redis.Expire(ctx, key, 24*time.Hour)
 
 	// Обновляем монеты в БД
-	if err := s.ChangeCoins(userId, dayCoins); err != nil {
-		s.redis.SRem(context.Background(), key, userId) // Откатываем Redis при ошибке БД
+	if err := s.ChangeCoins(ctx, userId, dayCoins); err != nil {
+		s.redis.SRem(ctx, key, userId) // Откатываем Redis при ошибке БД
 		return err
 	}
 
+	metrics.DailyRewardClaims.Inc()
 	return nil
 }
 
@@ -141,12 +212,15 @@ func (s *UserSettingsService) startSubscriptionChecker() {
 	logrus.Infof("Фоновая задача: проверка подписок каждые %v", checkExpiredSubscriptionsInterval)
 
 	for range ticker.C {
-		rowsAffected, err := s.repo.DeactivateExpiredSubscriptions()
+		start := time.Now()
+		rowsAffected, err := s.repo.DeactivateExpiredSubscriptions(context.Background())
+		metrics.ObserveJob(metrics.JobSubscriptionChecker, start, err)
 		if err != nil {
 			logrus.Errorf("Ошибка при деактивации подписок: %v", err)
 			continue
 		}
 
+		metrics.SubscriptionsDeactivated.Add(float64(rowsAffected))
 		if rowsAffected > 0 {
 			logrus.Infof("Деактивировано %d просроченных подписок", rowsAffected)
 		}
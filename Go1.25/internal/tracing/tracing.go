@@ -0,0 +1,111 @@
+// Package tracing настраивает OpenTelemetry: экспорт спанов (OTLP или stdout),
+// семплирование и распространение контекста трассировки между сервисами.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.40.0"
+)
+
+// Куда отправлять спаны
+const (
+	ExporterNone   = "none"
+	ExporterOTLP   = "otlp"
+	ExporterStdout = "stdout"
+)
+
+// InstrumentationName — имя трассировщика приложения
+const InstrumentationName = "github.com/ArtemChadaev/SeeThisGame"
+
+type Config struct {
+	// Exporter — none, otlp (коллектор по OTLP/HTTP) или stdout (для локального запуска)
+	Exporter string `mapstructure:"exporter"`
+	// Endpoint — host:port коллектора. Пусто — берется из OTEL_EXPORTER_OTLP_ENDPOINT или localhost:4318.
+	Endpoint string `mapstructure:"endpoint"`
+	// Insecure — отправлять в коллектор по HTTP без TLS
+	Insecure bool `mapstructure:"insecure"`
+	// SampleRatio — доля записываемых трасс (0..1]; решение родительского спана сохраняется
+	SampleRatio float64 `mapstructure:"sampleRatio"`
+	ServiceName string  `mapstructure:"serviceName"`
+	// ShutdownTimeout — сколько ждать отправки оставшихся спанов при остановке. 0 — 5 секунд.
+	ShutdownTimeout time.Duration `mapstructure:"shutdownTimeout"`
+}
+
+// Init регистрирует глобальный TracerProvider. Возвращает функцию, которая при остановке
+// приложения отправляет оставшиеся спаны, но не дольше ShutdownTimeout.
+// С exporter none спаны не создаются, но заголовки traceparent все равно передаются дальше.
+func Init(ctx context.Context, cfg Config) (func(context.Context) error, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case "", ExporterNone:
+		return func(context.Context) error { return nil }, nil
+	case ExporterOTLP:
+		options := []otlptracehttp.Option{}
+		if cfg.Endpoint != "" {
+			options = append(options, otlptracehttp.WithEndpoint(cfg.Endpoint))
+		}
+		if cfg.Insecure {
+			options = append(options, otlptracehttp.WithInsecure())
+		}
+		exporter, err = otlptracehttp.New(ctx, options...)
+	case ExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		return nil, fmt.Errorf("unknown tracing exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	serviceName := cfg.ServiceName
+	if serviceName == "" {
+		serviceName = "seethisgame"
+	}
+	res, err := resource.New(ctx,
+		resource.WithAttributes(semconv.ServiceName(serviceName)),
+		resource.WithFromEnv(),
+		resource.WithTelemetrySDK(),
+		resource.WithHost(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	ratio := cfg.SampleRatio
+	if ratio <= 0 || ratio > 1 {
+		ratio = 1
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+		sdktrace.WithSampler(sdktrace.ParentBased(sdktrace.TraceIDRatioBased(ratio))),
+	)
+	otel.SetTracerProvider(provider)
+
+	timeout := cfg.ShutdownTimeout
+	if timeout <= 0 {
+		timeout = 5 * time.Second
+	}
+	return func(ctx context.Context) error {
+		ctx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+		return provider.Shutdown(ctx)
+	}, nil
+}
@@ -0,0 +1,111 @@
+package rest
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/ArtemChadaev/SeeThisGame/internal/logger"
+	"github.com/ArtemChadaev/SeeThisGame/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// exportAccount отдает архив с данными пользователя сразу в ответе
+func (h *Handler) exportAccount(c *gin.Context) {
+	userId, err := getUserID(c)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	export, err := h.services.AccountService.ExportAccount(c.Request.Context(), userId)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.Header("Content-Disposition", `attachment; filename="`+export.FileName+`"`)
+	c.Header("Cache-Control", "no-store")
+	c.Data(http.StatusOK, "application/zip", export.Data)
+}
+
+func (h *Handler) deleteAccount(c *gin.Context) {
+	claims, err := getTokenClaims(c)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	// Пароль нужен, только если он задан; без пароля — вход не раньше 10 минут назад, гостю не нужно ничего.
+	// Code — код TOTP или код восстановления, если включена двухфакторная аутентификация.
+	var input struct {
+		Password string `json:"password"`
+		Code     string `json:"code"`
+	}
+	if c.Request.ContentLength != 0 {
+		if err := c.BindJSON(&input); err != nil {
+			handleError(c, domain.NewInvalidRequestError(err))
+			return
+		}
+	}
+
+	deleteAt, err := h.services.AccountService.DeleteAccount(c.Request.Context(), claims.UserID, claims.SessionID, input.Password, input.Code, deviceInfo(c))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"message":  "Аккаунт будет удален. Войдите до этой даты, чтобы отменить удаление",
+		"deleteAt": deleteAt,
+	})
+}
+
+// upgradeAccount превращает гостя в обычный аккаунт: email и пароль сразу,
+// или провайдер входа — тогда, как и при привязке, отдаем ссылку на провайдера.
+func (h *Handler) upgradeAccount(c *gin.Context) {
+	userId, err := getUserID(c)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	var input struct {
+		Email    string `json:"email" binding:"omitempty,email,max=255"`
+		Password string `json:"password" binding:"omitempty,min=8,max=128"`
+		Provider string `json:"provider"`
+	}
+	if err := c.BindJSON(&input); err != nil {
+		handleError(c, domain.NewInvalidRequestError(err))
+		return
+	}
+
+	if input.Provider != "" {
+		url, state, err := h.services.AccountService.BeginGuestUpgrade(c.Request.Context(), userId, input.Provider)
+		if err != nil {
+			handleError(c, err)
+			return
+		}
+
+		setOAuthStateCookie(c, state, int(service.OAuthStateTTL.Seconds()))
+		c.JSON(http.StatusOK, gin.H{"url": url})
+		return
+	}
+
+	if input.Email == "" || input.Password == "" {
+		handleError(c, domain.NewInvalidRequestError(errors.New("email and password or provider are required")))
+		return
+	}
+
+	if err := h.services.AccountService.UpgradeGuest(c.Request.Context(), userId, input.Email, input.Password); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	// Как и при регистрации, письмо не должно ломать переход — его можно запросить повторно
+	if err := h.services.EmailVerificationService.SendVerificationEmail(c.Request.Context(), userId); err != nil {
+		logger.FromContext(c.Request.Context()).Errorf("failed to send verification email to user %d: %v", userId, err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Аккаунт зарегистрирован"})
+}
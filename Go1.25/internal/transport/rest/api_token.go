@@ -0,0 +1,100 @@
+package rest
+
+import (
+	"net/http"
+	"slices"
+	"strconv"
+	"time"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/gin-gonic/gin"
+)
+
+// apiTokenRoutes — маршруты, доступные персональным токенам, и нужная для них область.
+// Всё остальное (сессии, пароль, MFA, ключи, сами токены, удаление аккаунта) открыто только
+// для входа пользователя: утечка токена бота не должна давать захват аккаунта.
+var apiTokenRoutes = map[string]string{
+	"GET /api/settings/":         domain.ScopeSettingsRead,
+	"PUT /api/settings/":         domain.ScopeSettingsWrite,
+	"POST /api/settings/dayCoin": domain.ScopeSettingsWrite,
+
+	"GET /api/admin/roles":                    domain.ScopeAdmin,
+	"GET /api/admin/users/:id/roles":          domain.ScopeAdmin,
+	"PUT /api/admin/users/:id/roles/:role":    domain.ScopeAdmin,
+	"DELETE /api/admin/users/:id/roles/:role": domain.ScopeAdmin,
+}
+
+// checkAPITokenScope пропускает запрос с персональным токеном, только если маршрут
+// есть в apiTokenRoutes и у токена есть его область.
+func checkAPITokenScope(c *gin.Context, claims domain.AccessTokenClaims) error {
+	scope, ok := apiTokenRoutes[c.Request.Method+" "+c.FullPath()]
+	if !ok || !slices.Contains(claims.Scopes, scope) {
+		return domain.ErrInsufficientScope
+	}
+	return nil
+}
+
+func (h *Handler) getAPITokens(c *gin.Context) {
+	userId, err := getUserID(c)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	tokens, err := h.services.APITokenService.GetAPITokens(c.Request.Context(), userId)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, tokens)
+}
+
+func (h *Handler) createAPIToken(c *gin.Context) {
+	userId, err := getUserID(c)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	var input struct {
+		Name   string   `json:"name" binding:"required,max=100"`
+		Scopes []string `json:"scopes" binding:"required,min=1"`
+		// ExpiresInDays — срок жизни токена; 0 или пусто — бессрочный
+		ExpiresInDays int `json:"expiresInDays" binding:"min=0,max=365"`
+	}
+	if err := c.BindJSON(&input); err != nil {
+		handleError(c, domain.NewInvalidRequestError(err))
+		return
+	}
+
+	ttl := time.Duration(input.ExpiresInDays) * 24 * time.Hour
+	token, err := h.services.APITokenService.CreateAPIToken(c.Request.Context(), userId, input.Name, input.Scopes, ttl)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, token)
+}
+
+func (h *Handler) deleteAPIToken(c *gin.Context) {
+	userId, err := getUserID(c)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handleError(c, domain.ErrAPITokenNotFound)
+		return
+	}
+
+	if err := h.services.APITokenService.DeleteAPIToken(c.Request.Context(), userId, id); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Токен отозван"})
+}
@@ -1,9 +1,11 @@
 package rest
 
 import (
+	"errors"
 	"net/http"
 
 	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/ArtemChadaev/SeeThisGame/internal/logger"
 	"github.com/gin-gonic/gin"
 )
 
@@ -15,18 +17,34 @@ func (h *Handler) signUp(c *gin.Context) {
 		return
 	}
 
-	_, err := h.services.AuthorizationService.CreateUser(input)
+	userId, err := h.services.AuthorizationService.CreateUser(c.Request.Context(), input)
 	if err != nil {
 		handleError(c, err)
 		return
 	}
 
-	tokens, err := h.services.AuthorizationService.GenerateTokens(input.Email, input.Password)
+	// Письмо не должно ломать регистрацию — его всегда можно запросить повторно
+	if err := h.services.EmailVerificationService.SendVerificationEmail(c.Request.Context(), userId); err != nil {
+		logger.FromContext(c.Request.Context()).Errorf("failed to send verification email to user %d: %v", userId, err)
+	}
+
+	result, err := h.services.AuthorizationService.GenerateTokens(c.Request.Context(), input.Email, input.Password, deviceInfo(c))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+	h.writeSignInResult(c, result)
+}
+
+// guestSignIn создает гостевой аккаунт: можно играть сразу, а зарегистрироваться позже
+func (h *Handler) guestSignIn(c *gin.Context) {
+	tokens, err := h.services.AuthorizationService.CreateGuest(c.Request.Context(), deviceInfo(c))
 	if err != nil {
 		handleError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, tokens)
+
+	h.writeTokens(c, tokens)
 }
 
 func (h *Handler) signIn(c *gin.Context) {
@@ -40,18 +58,20 @@ func (h *Handler) signIn(c *gin.Context) {
 		return
 	}
 
-	tokens, err := h.services.AuthorizationService.GenerateTokens(input.Email, input.Password)
+	result, err := h.services.AuthorizationService.GenerateTokens(c.Request.Context(), input.Email, input.Password, deviceInfo(c))
 	if err != nil {
 		handleError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, tokens)
+	h.writeSignInResult(c, result)
 }
 
-func (h *Handler) updateToken(c *gin.Context) {
+// signInMFA — второй шаг входа: код TOTP или код восстановления
+func (h *Handler) signInMFA(c *gin.Context) {
 	var input struct {
-		RefreshToken string `json:"refreshToken" binding:"required"`
+		MFAToken string `json:"mfaToken" binding:"required"`
+		Code     string `json:"code" binding:"required"`
 	}
 
 	if err := c.BindJSON(&input); err != nil {
@@ -59,10 +79,94 @@ func (h *Handler) updateToken(c *gin.Context) {
 		return
 	}
 
-	tokens, err := h.services.AuthorizationService.GetAccessToken(input.RefreshToken)
+	tokens, err := h.services.MFAService.CompleteSignIn(c.Request.Context(), input.MFAToken, input.Code, deviceInfo(c))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	h.writeTokens(c, tokens)
+}
+
+// writeSignInResult отдает токены или MFA challenge, если нужен второй фактор
+func (h *Handler) writeSignInResult(c *gin.Context, result domain.SignInResult) {
+	if result.Linked != nil {
+		c.JSON(http.StatusOK, gin.H{"message": "Аккаунт привязан", "identity": result.Linked})
+		return
+	}
+	if result.Challenge != nil {
+		c.JSON(http.StatusOK, result.Challenge)
+		return
+	}
+	h.writeTokens(c, *result.Tokens)
+}
+
+// updateToken обменивает refresh токен из JSON или, в режиме cookie, из cookie refresh_token
+func (h *Handler) updateToken(c *gin.Context) {
+	refreshToken, err := cookieRefreshToken(c)
 	if err != nil {
 		handleError(c, err)
 		return
 	}
-	c.JSON(http.StatusOK, tokens)
+
+	if refreshToken != "" {
+		// Ответ должен снова поставить cookie, даже если клиент не прислал заголовок режима
+		c.Request.Header.Set(sessionModeHeader, sessionModeCookie)
+	} else {
+		var input struct {
+			RefreshToken string `json:"refreshToken" binding:"required"`
+		}
+		if err := c.BindJSON(&input); err != nil {
+			handleError(c, domain.NewInvalidRequestError(err))
+			return
+		}
+		refreshToken = input.RefreshToken
+	}
+
+	tokens, err := h.services.AuthorizationService.GetAccessToken(c.Request.Context(), refreshToken, deviceInfo(c))
+	if err != nil {
+		if errors.Is(err, domain.ErrInvalidToken) {
+			h.clearSessionCookies(c)
+		}
+		handleError(c, err)
+		return
+	}
+	h.writeTokens(c, tokens)
+}
+
+func (h *Handler) logout(c *gin.Context) {
+	claims, err := getTokenClaims(c)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	if err := h.services.AuthorizationService.UnAuthorize(c.Request.Context(), claims); err != nil {
+		handleError(c, err)
+		return
+	}
+	h.clearSessionCookies(c)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Выход выполнен"})
+}
+
+func (h *Handler) logoutAll(c *gin.Context) {
+	userId, err := getUserID(c)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	if err := h.services.AuthorizationService.UnAuthorizeAll(c.Request.Context(), userId); err != nil {
+		handleError(c, err)
+		return
+	}
+	h.clearSessionCookies(c)
+
+	c.JSON(http.StatusOK, gin.H{"message": "Выход выполнен на всех устройствах"})
+}
+
+func (h *Handler) getJWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, h.services.AuthorizationService.GetJWKS())
 }
@@ -0,0 +1,40 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) sendVerificationEmail(c *gin.Context) {
+	userId, err := getUserID(c)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	if err := h.services.EmailVerificationService.SendVerificationEmail(c.Request.Context(), userId); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusAccepted, gin.H{"message": "Письмо отправлено"})
+}
+
+func (h *Handler) verifyEmail(c *gin.Context) {
+	var input struct {
+		Token string `json:"token" binding:"required"`
+	}
+	if err := c.BindJSON(&input); err != nil {
+		handleError(c, domain.NewInvalidRequestError(err))
+		return
+	}
+
+	if err := h.services.EmailVerificationService.ConfirmEmail(c.Request.Context(), input.Token); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Email подтвержден"})
+}
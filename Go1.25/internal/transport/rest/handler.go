@@ -1,33 +1,62 @@
 package rest
 
 import (
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
 	"github.com/ArtemChadaev/SeeThisGame/internal/service"
 	"github.com/gin-gonic/gin"
 	"github.com/redis/go-redis/v9"
+	"go.opentelemetry.io/contrib/instrumentation/github.com/gin-gonic/gin/otelgin"
 )
 
 type Handler struct {
-	services *service.Service
-	redis    *redis.Client
+	services   *service.Service
+	redis      *redis.Client
+	cookies    SessionCookieConfig
+	rateLimits RateLimitConfig
 }
 
-func NewHandler(services *service.Service, redis *redis.Client) *Handler {
+func NewHandler(services *service.Service, redis *redis.Client, cookies SessionCookieConfig, rateLimits RateLimitConfig) *Handler {
 	return &Handler{
-		services: services,
-		redis:    redis,
+		services:   services,
+		redis:      redis,
+		cookies:    cookies,
+		rateLimits: rateLimits,
 	}
 }
 
 // InitRoutes настраивает маршруты приложения
 func (h *Handler) InitRoutes() *gin.Engine {
 	router := gin.New()
+	// Спан на каждый запрос (кроме проб), затем request id, логгер и метрики
+	router.Use(otelgin.Middleware("seethisgame", otelgin.WithGinFilter(func(c *gin.Context) bool {
+		return !probeRoutes[c.FullPath()]
+	})))
+	router.Use(requestContext, httpMetrics)
+
+	// Пробы liveness/readiness. Метрики — на отдельном внутреннем порту (MetricsHandler)
+	router.GET("/healthz", h.healthz)
+	router.GET("/readyz", h.readyz)
+
+	// Публичные ключи для проверки access токенов другими сервисами (n8n, игровой сервер)
+	router.GET("/.well-known/jwks.json", h.getJWKS)
 
 	// Группа авторизации с ограничением по IP
-	auth := router.Group("/auth", h.authRateLimiter)
+	auth := router.Group("/auth", h.rateLimit("auth"))
 	{
 		auth.POST("/sign-up", h.signUp)
 		auth.POST("/sign-in", h.signIn)
+		auth.POST("/guest", h.guestSignIn)
+		auth.POST("/sign-in/mfa", h.signInMFA)
 		auth.POST("/refresh", h.updateToken)
+		auth.POST("/logout", h.userIdentify, h.logout)
+		auth.POST("/logout-all", h.userIdentify, h.logoutAll)
+		auth.POST("/email/verify", h.verifyEmail)
+		auth.POST("/password/forgot", h.forgotPassword)
+		auth.POST("/password/reset", h.resetPassword)
+		auth.POST("/passkey/login/begin", h.beginPasskeyLogin)
+		auth.POST("/passkey/login/finish", h.finishPasskeyLogin)
+		auth.POST("/telegram", h.telegramSignIn)
+		auth.GET("/telegram", h.telegramRedirect)
 
 		oauth := auth.Group("/oauth")
 		{
@@ -37,14 +66,69 @@ func (h *Handler) InitRoutes() *gin.Engine {
 	}
 
 	// Группа API с проверкой токена и лимитом запросов
-	api := router.Group("/api", h.userIdentify, h.rateLimiter)
+	api := router.Group("/api", h.userIdentify, h.rateLimit("api"))
 	{
 		settings := api.Group("/settings")
 		{
 			settings.GET("/", h.getMySettings)
 			settings.PUT("/", h.setNameIcon)
-			settings.POST("/dayCoin", h.dayCoin)
-			// settings.POST("/subscript", h.subscribe) // Добавь хендлер, когда будет готов
+			settings.POST("/dayCoin", h.requireVerifiedEmail, h.dayCoin)
+		}
+
+		api.POST("/email/verification", h.sendVerificationEmail)
+		api.PUT("/password", h.changePassword)
+
+		account := api.Group("/account")
+		{
+			account.POST("/export", h.requireVerifiedEmail, h.exportAccount)
+			account.DELETE("", h.deleteAccount)
+			account.POST("/upgrade", h.upgradeAccount)
+		}
+
+		mfa := api.Group("/mfa")
+		{
+			mfa.POST("/totp", h.enrollTOTP)
+			mfa.POST("/totp/confirm", h.confirmTOTP)
+			mfa.DELETE("/totp", h.disableTOTP)
+			mfa.POST("/recovery-codes", h.regenerateRecoveryCodes)
+		}
+
+		passkeys := api.Group("/passkeys")
+		{
+			passkeys.GET("/", h.getPasskeys)
+			passkeys.POST("/register/begin", h.beginPasskeyRegistration)
+			passkeys.POST("/register/finish", h.finishPasskeyRegistration)
+			passkeys.DELETE("/:id", h.deletePasskey)
+		}
+
+		identities := api.Group("/identities")
+		{
+			identities.GET("/", h.getIdentities)
+			identities.POST("/:provider/link", h.linkIdentity)
+			identities.DELETE("/:provider", h.unlinkIdentity)
+		}
+
+		tokens := api.Group("/tokens")
+		{
+			tokens.GET("/", h.getAPITokens)
+			tokens.POST("/", h.requireVerifiedEmail, h.createAPIToken)
+			tokens.DELETE("/:id", h.deleteAPIToken)
+		}
+
+		sessions := api.Group("/sessions")
+		{
+			sessions.GET("/", h.getSessions)
+			sessions.PATCH("/:id", h.renameSession)
+			sessions.DELETE("/:id", h.deleteSession)
+		}
+
+		// Администрирование: доступ по правам из ролей в access токене
+		admin := api.Group("/admin")
+		{
+			admin.GET("/roles", h.requirePermission(domain.PermissionRolesRead), h.getRoles)
+			admin.GET("/users/:id/roles", h.requirePermission(domain.PermissionRolesRead), h.getUserRoles)
+			admin.PUT("/users/:id/roles/:role", h.requirePermission(domain.PermissionRolesManage), h.grantRole)
+			admin.DELETE("/users/:id/roles/:role", h.requirePermission(domain.PermissionRolesManage), h.revokeRole)
 		}
 	}
 
@@ -0,0 +1,33 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/gin-gonic/gin"
+)
+
+// probeRoutes — маршруты, которые опрашиваются каждые несколько секунд: их не пишем
+// в access лог и трассировку, чтобы не забивать их шумом
+var probeRoutes = map[string]bool{
+	"/healthz": true,
+	"/readyz":  true,
+}
+
+// healthz — процесс жив и обслуживает HTTP. Зависимости не проверяются:
+// перезапуск контейнера не поможет, если недоступен Postgres.
+func (h *Handler) healthz(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": domain.HealthStatusOK})
+}
+
+// readyz — можно ли направлять трафик: Postgres, Redis и версия схемы в порядке,
+// сервер не останавливается
+func (h *Handler) readyz(c *gin.Context) {
+	readiness := h.services.HealthService.Readiness(c.Request.Context())
+
+	status := http.StatusOK
+	if readiness.Status != domain.HealthStatusOK {
+		status = http.StatusServiceUnavailable
+	}
+	c.JSON(status, readiness)
+}
@@ -0,0 +1,58 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) getIdentities(c *gin.Context) {
+	userId, err := getUserID(c)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	identities, err := h.services.IdentityService.GetIdentities(c.Request.Context(), userId)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, identities)
+}
+
+// linkIdentity начинает привязку провайдера. Запрос идет с access токеном,
+// поэтому вместо редиректа отдаем ссылку — фронтенд сам переходит по ней.
+func (h *Handler) linkIdentity(c *gin.Context) {
+	userId, err := getUserID(c)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	url, state, err := h.services.OAuthService.GetLinkURL(c.Request.Context(), userId, c.Param("provider"))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	setOAuthStateCookie(c, state, int(service.OAuthStateTTL.Seconds()))
+	c.JSON(http.StatusOK, gin.H{"url": url})
+}
+
+func (h *Handler) unlinkIdentity(c *gin.Context) {
+	userId, err := getUserID(c)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	if err := h.services.IdentityService.UnlinkIdentity(c.Request.Context(), userId, c.Param("provider")); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Способ входа отвязан"})
+}
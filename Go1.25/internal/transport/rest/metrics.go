@@ -0,0 +1,52 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/metrics"
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// knownMethods — методы, которые попадают в метку method как есть. Любой другой
+// (клиент может прислать что угодно на несуществующий маршрут) считается как other.
+var knownMethods = map[string]bool{
+	http.MethodGet:     true,
+	http.MethodHead:    true,
+	http.MethodPost:    true,
+	http.MethodPut:     true,
+	http.MethodPatch:   true,
+	http.MethodDelete:  true,
+	http.MethodOptions: true,
+}
+
+// MetricsHandler отдает /metrics для Prometheus. Он запускается на отдельном порту (metricsPort),
+// который не публикуется наружу, а не на публичном роутере API.
+func MetricsHandler() http.Handler {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", promhttp.Handler())
+	return mux
+}
+
+// httpMetrics считает запросы и время их обработки по шаблону маршрута,
+// а не по пути: иначе каждый id в URL создавал бы новую серию
+func httpMetrics(c *gin.Context) {
+	start := time.Now()
+
+	c.Next()
+
+	route := c.FullPath()
+	if route == "" {
+		route = "not_found"
+	}
+	method := c.Request.Method
+	if !knownMethods[method] {
+		method = "other"
+	}
+	status := strconv.Itoa(c.Writer.Status())
+
+	metrics.HTTPRequests.WithLabelValues(method, route, status).Inc()
+	metrics.HTTPRequestDuration.WithLabelValues(method, route, status).Observe(time.Since(start).Seconds())
+}
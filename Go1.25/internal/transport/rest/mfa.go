@@ -0,0 +1,93 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/gin-gonic/gin"
+)
+
+type mfaCodeInput struct {
+	Code string `json:"code" binding:"required"`
+}
+
+func (h *Handler) enrollTOTP(c *gin.Context) {
+	userId, err := getUserID(c)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	enrollment, err := h.services.MFAService.EnrollTOTP(c.Request.Context(), userId)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, enrollment)
+}
+
+func (h *Handler) confirmTOTP(c *gin.Context) {
+	userId, err := getUserID(c)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	var input mfaCodeInput
+	if err := c.BindJSON(&input); err != nil {
+		handleError(c, domain.NewInvalidRequestError(err))
+		return
+	}
+
+	codes, err := h.services.MFAService.ConfirmTOTP(c.Request.Context(), userId, input.Code)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
+
+func (h *Handler) disableTOTP(c *gin.Context) {
+	userId, err := getUserID(c)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	var input mfaCodeInput
+	if err := c.BindJSON(&input); err != nil {
+		handleError(c, domain.NewInvalidRequestError(err))
+		return
+	}
+
+	if err := h.services.MFAService.DisableTOTP(c.Request.Context(), userId, input.Code); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Двухфакторная аутентификация отключена"})
+}
+
+func (h *Handler) regenerateRecoveryCodes(c *gin.Context) {
+	userId, err := getUserID(c)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	var input mfaCodeInput
+	if err := c.BindJSON(&input); err != nil {
+		handleError(c, domain.NewInvalidRequestError(err))
+		return
+	}
+
+	codes, err := h.services.MFAService.RegenerateRecoveryCodes(c.Request.Context(), userId, input.Code)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"recoveryCodes": codes})
+}
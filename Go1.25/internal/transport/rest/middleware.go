@@ -1,9 +1,7 @@
 package rest
 
 import (
-	"context"
 	"strings"
-	"time"
 
 	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
 	"github.com/gin-gonic/gin"
@@ -12,12 +10,7 @@ import (
 const (
 	authorizationHeader = "Authorization"
 	userCtx             = "userId"
-
-	rateLimitPerMinute = 20
-	rateWindow         = 1 * time.Minute
-
-	authRateLimitPerMinute = 10
-	authRateWindow         = 1 * time.Minute
+	claimsCtx           = "tokenClaims"
 )
 
 // userIdentify — проверка валидности Access токена
@@ -34,72 +27,82 @@ func (h *Handler) userIdentify(c *gin.Context) {
 		return
 	}
 
-	// Вызываем сервис через интерфейс
-	userId, err := h.services.AuthorizationService.ParseToken(headerParts[1])
+	// Персональный токен (бот, скрипт) или access токен (JWT)
+	var claims domain.AccessTokenClaims
+	var err error
+	if strings.HasPrefix(headerParts[1], domain.APITokenPrefix) {
+		claims, err = h.services.APITokenService.ParseAPIToken(c.Request.Context(), headerParts[1])
+		if err == nil {
+			err = checkAPITokenScope(c, claims)
+		}
+	} else {
+		claims, err = h.services.AuthorizationService.ParseToken(c.Request.Context(), headerParts[1])
+	}
 	if err != nil {
 		handleError(c, err)
 		return
 	}
 
-	c.Set(userCtx, userId)
+	c.Set(userCtx, claims.UserID)
+	c.Set(claimsCtx, claims)
+	setRequestUser(c, claims.UserID)
 }
 
-// rateLimiter — ограничение частоты запросов по токену через Redis
-func (h *Handler) rateLimiter(c *gin.Context) {
-	header := c.GetHeader(authorizationHeader)
-	if header == "" {
-		c.Next()
-		return
+// getTokenClaims возвращает данные access токена, сохраненные userIdentify
+func getTokenClaims(c *gin.Context) (domain.AccessTokenClaims, error) {
+	claims, ok := c.Get(claimsCtx)
+	if !ok {
+		return domain.AccessTokenClaims{}, domain.ErrInvalidToken
 	}
 
-	headerParts := strings.Split(header, " ")
-	if len(headerParts) != 2 {
-		c.Next()
-		return
+	tokenClaims, ok := claims.(domain.AccessTokenClaims)
+	if !ok {
+		return domain.AccessTokenClaims{}, domain.ErrInvalidToken
 	}
-	accessToken := headerParts[1]
 
-	ctx := context.Background()
-	key := "rate_limit:" + accessToken
+	return tokenClaims, nil
+}
 
-	pipe := h.redis.Pipeline()
-	incr := pipe.Incr(ctx, key)
-	pipe.Expire(ctx, key, rateWindow)
-	_, err := pipe.Exec(ctx)
+// requirePermission — доступ только с правом permission. Ставится после userIdentify:
+// роли берутся из access токена, права ролей — из RoleService.
+func (h *Handler) requirePermission(permission string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		claims, err := getTokenClaims(c)
+		if err != nil {
+			handleError(c, err)
+			return
+		}
+
+		allowed, err := h.services.RoleService.HasPermission(c.Request.Context(), claims.Roles, permission)
+		if err != nil {
+			handleError(c, err)
+			return
+		}
+		if !allowed {
+			handleError(c, domain.ErrForbidden)
+			return
+		}
 
-	if err != nil {
-		c.Next() // Если Redis упал, не блокируем пользователя
-		return
+		c.Next()
 	}
+}
 
-	if incr.Val() > rateLimitPerMinute {
-		handleError(c, domain.ErrTooManyRequestsByAccessToken)
-		c.Abort()
+// requireVerifiedEmail — доступ только для пользователей с подтвержденным email:
+// начисление монет, выпуск API токенов, выгрузка данных и будущие покупки
+func (h *Handler) requireVerifiedEmail(c *gin.Context) {
+	userId, err := getUserID(c)
+	if err != nil {
+		handleError(c, err)
 		return
 	}
 
-	c.Next()
-}
-
-// authRateLimiter — ограничение запросов к /auth по IP адресу
-func (h *Handler) authRateLimiter(c *gin.Context) {
-	ip := c.ClientIP()
-	key := "rate_limit_auth:" + ip
-	ctx := context.Background()
-
-	pipe := h.redis.Pipeline()
-	incr := pipe.Incr(ctx, key)
-	pipe.Expire(ctx, key, authRateWindow)
-	_, err := pipe.Exec(ctx)
-
+	verified, err := h.services.EmailVerificationService.IsEmailVerified(c.Request.Context(), userId)
 	if err != nil {
-		c.Next()
+		handleError(c, err)
 		return
 	}
-
-	if incr.Val() > authRateLimitPerMinute {
-		handleError(c, domain.ErrTooManyRequestsByIp)
-		c.Abort()
+	if !verified {
+		handleError(c, domain.ErrEmailNotVerified)
 		return
 	}
 
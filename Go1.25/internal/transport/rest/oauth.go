@@ -4,46 +4,50 @@ import (
 	"net/http"
 
 	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/ArtemChadaev/SeeThisGame/internal/service"
 	"github.com/gin-gonic/gin"
 )
 
-func (h *Handler) initiateOAuth(c *gin.Context) {
-	provider := c.Param("provider")
-	if provider != "google" && provider != "github" {
-		handleError(c, domain.NewInvalidRequestError(nil))
-		return
-	}
+// oauthStateCookie привязывает попытку входа к браузеру, который ее начал
+const oauthStateCookie = "oauth_state"
+
+// setOAuthStateCookie ставит (или при maxAge < 0 удаляет) cookie со state.
+// SameSite=Lax: cookie должна прийти при возврате с сайта провайдера.
+func setOAuthStateCookie(c *gin.Context, state string, maxAge int) {
+	secure := c.Request.TLS != nil || c.GetHeader("X-Forwarded-Proto") == "https"
+	c.SetSameSite(http.SameSiteLaxMode)
+	c.SetCookie(oauthStateCookie, state, maxAge, "/auth/oauth", "", secure, true)
+}
 
-	url, err := h.services.OAuthService.GetAuthURL(provider)
+func (h *Handler) initiateOAuth(c *gin.Context) {
+	url, state, err := h.services.OAuthService.GetAuthURL(c.Request.Context(), c.Param("provider"))
 	if err != nil {
 		handleError(c, err)
 		return
 	}
 
+	setOAuthStateCookie(c, state, int(service.OAuthStateTTL.Seconds()))
+
 	c.Redirect(http.StatusTemporaryRedirect, url)
 }
 
 func (h *Handler) oauthCallback(c *gin.Context) {
-	provider := c.Param("provider")
-	if provider != "google" && provider != "github" {
-		handleError(c, domain.NewInvalidRequestError(nil))
-		return
-	}
+	// State одноразовый — cookie больше не нужна при любом исходе
+	browserState, _ := c.Cookie(oauthStateCookie)
+	setOAuthStateCookie(c, "", -1)
 
 	// Код и стейт приходят в URL (query)
-	code := c.Query("code")
-	if code == "" {
-		handleError(c, domain.NewInvalidRequestError(nil))
+	var input domain.OAuthCallbackRequest
+	if err := c.ShouldBindQuery(&input); err != nil {
+		handleError(c, domain.NewInvalidRequestError(err))
 		return
 	}
 
-	// TODO: Проверка параметра state для защиты от CSRF
-
-	tokens, err := h.services.OAuthService.HandleCallback(provider, code)
+	result, err := h.services.OAuthService.HandleCallback(c.Request.Context(), c.Param("provider"), input.Code, input.State, browserState, deviceInfo(c))
 	if err != nil {
 		handleError(c, err)
 		return
 	}
 
-	c.JSON(http.StatusOK, tokens)
+	h.writeSignInResult(c, result)
 }
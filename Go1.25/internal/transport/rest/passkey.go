@@ -0,0 +1,118 @@
+package rest
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/gin-gonic/gin"
+)
+
+// passkeyFinishInput — ответ navigator.credentials.create/get как есть и id церемонии
+type passkeyFinishInput struct {
+	CeremonyID string          `json:"ceremonyId" binding:"required"`
+	Credential json.RawMessage `json:"credential" binding:"required"`
+	Name       string          `json:"name" binding:"max=255"`
+}
+
+func (h *Handler) beginPasskeyRegistration(c *gin.Context) {
+	userId, err := getUserID(c)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	ceremony, err := h.services.PasskeyService.BeginPasskeyRegistration(c.Request.Context(), userId)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ceremony)
+}
+
+func (h *Handler) finishPasskeyRegistration(c *gin.Context) {
+	userId, err := getUserID(c)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	var input passkeyFinishInput
+	if err := c.BindJSON(&input); err != nil {
+		handleError(c, domain.NewInvalidRequestError(err))
+		return
+	}
+
+	passkey, err := h.services.PasskeyService.FinishPasskeyRegistration(c.Request.Context(), userId, input.CeremonyID, input.Name, input.Credential)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusCreated, passkey)
+}
+
+func (h *Handler) getPasskeys(c *gin.Context) {
+	userId, err := getUserID(c)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	passkeys, err := h.services.PasskeyService.GetPasskeys(c.Request.Context(), userId)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, passkeys)
+}
+
+func (h *Handler) deletePasskey(c *gin.Context) {
+	userId, err := getUserID(c)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	id, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handleError(c, domain.ErrPasskeyNotFound)
+		return
+	}
+
+	if err := h.services.PasskeyService.DeletePasskey(c.Request.Context(), userId, id); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Ключ доступа удален"})
+}
+
+func (h *Handler) beginPasskeyLogin(c *gin.Context) {
+	ceremony, err := h.services.PasskeyService.BeginPasskeyLogin(c.Request.Context())
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, ceremony)
+}
+
+func (h *Handler) finishPasskeyLogin(c *gin.Context) {
+	var input passkeyFinishInput
+	if err := c.BindJSON(&input); err != nil {
+		handleError(c, domain.NewInvalidRequestError(err))
+		return
+	}
+
+	tokens, err := h.services.PasskeyService.FinishPasskeyLogin(c.Request.Context(), input.CeremonyID, input.Credential, deviceInfo(c))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	h.writeTokens(c, tokens)
+}
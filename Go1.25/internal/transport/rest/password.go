@@ -0,0 +1,69 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) forgotPassword(c *gin.Context) {
+	var input struct {
+		Email string `json:"email" binding:"required,email"`
+	}
+	if err := c.BindJSON(&input); err != nil {
+		handleError(c, domain.NewInvalidRequestError(err))
+		return
+	}
+
+	if err := h.services.PasswordService.RequestPasswordReset(c.Request.Context(), input.Email); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	// Ответ одинаковый для существующих и несуществующих email
+	c.JSON(http.StatusAccepted, gin.H{"message": "Если аккаунт существует, письмо отправлено"})
+}
+
+func (h *Handler) resetPassword(c *gin.Context) {
+	var input struct {
+		Token    string `json:"token" binding:"required"`
+		Password string `json:"password" binding:"required,min=8,max=128"`
+	}
+	if err := c.BindJSON(&input); err != nil {
+		handleError(c, domain.NewInvalidRequestError(err))
+		return
+	}
+
+	if err := h.services.PasswordService.ResetPassword(c.Request.Context(), input.Token, input.Password); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Пароль изменен"})
+}
+
+func (h *Handler) changePassword(c *gin.Context) {
+	userId, err := getUserID(c)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	var input struct {
+		CurrentPassword string `json:"currentPassword" binding:"required"`
+		NewPassword     string `json:"newPassword" binding:"required,min=8,max=128"`
+	}
+	if err := c.BindJSON(&input); err != nil {
+		handleError(c, domain.NewInvalidRequestError(err))
+		return
+	}
+
+	tokens, err := h.services.PasswordService.ChangePassword(c.Request.Context(), userId, input.CurrentPassword, input.NewPassword, deviceInfo(c))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	h.writeTokens(c, tokens)
+}
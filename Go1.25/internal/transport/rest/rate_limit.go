@@ -0,0 +1,147 @@
+package rest
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/ArtemChadaev/SeeThisGame/internal/logger"
+	"github.com/ArtemChadaev/SeeThisGame/internal/metrics"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/redis/go-redis/v9"
+	"github.com/sirupsen/logrus"
+)
+
+// Ключ, по которому считаются запросы политики
+const (
+	rateLimitKeyIP   = "ip"
+	rateLimitKeyUser = "user"
+)
+
+// RateLimitPolicy — не больше Limit запросов за любые Window подряд (скользящее окно)
+type RateLimitPolicy struct {
+	Limit  int           `mapstructure:"limit"`
+	Window time.Duration `mapstructure:"window"`
+	// Key — ip или user (id из access токена, ставится после userIdentify; без него — ip)
+	Key string `mapstructure:"key"`
+	// Tiers — лимит для тарифа пользователя (free, paid) вместо Limit. Только для key: user.
+	Tiers map[string]int `mapstructure:"tiers"`
+}
+
+// RateLimitConfig — политики по именам групп маршрутов (auth, api, ...)
+type RateLimitConfig struct {
+	Policies map[string]RateLimitPolicy `mapstructure:"policies"`
+}
+
+// defaultRateLimitPolicies действуют, если группа не описана в конфиге
+var defaultRateLimitPolicies = map[string]RateLimitPolicy{
+	"auth": {Limit: 10, Window: time.Minute, Key: rateLimitKeyIP},
+	"api":  {Limit: 20, Window: time.Minute, Key: rateLimitKeyUser},
+}
+
+// slidingWindowScript атомарно ведет журнал запросов в sorted set: удаляет вышедшие
+// из окна, добавляет текущий, если лимит не исчерпан. Отклоненные запросы не записываются,
+// поэтому постоянный поток запросов не продлевает блокировку.
+// Возвращает {разрешен (0/1), запросов в окне, мс до освобождения места}.
+var slidingWindowScript = redis.NewScript(`
+local key = KEYS[1]
+local window = tonumber(ARGV[1])
+local limit = tonumber(ARGV[2])
+local member = ARGV[3]
+
+local time = redis.call('TIME')
+local now = tonumber(time[1]) * 1000 + math.floor(tonumber(time[2]) / 1000)
+
+redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+local count = redis.call('ZCARD', key)
+local allowed = 0
+if count < limit then
+	redis.call('ZADD', key, now, member)
+	count = count + 1
+	allowed = 1
+end
+redis.call('PEXPIRE', key, window)
+
+local reset = window
+local oldest = redis.call('ZRANGE', key, 0, 0, 'WITHSCORES')
+if oldest[2] then
+	reset = tonumber(oldest[2]) + window - now
+end
+return {allowed, count, reset}
+`)
+
+// rateLimit возвращает middleware для политики name. Неизвестная политика — ошибка
+// конфигурации, поэтому о ней сообщаем при старте, а не на каждом запросе.
+func (h *Handler) rateLimit(name string) gin.HandlerFunc {
+	policy, ok := h.rateLimits.Policies[name]
+	if !ok {
+		policy, ok = defaultRateLimitPolicies[name]
+	}
+	if !ok || policy.Limit <= 0 || policy.Window <= 0 {
+		logrus.Fatalf("rate limit policy %q is not configured", name)
+	}
+
+	return func(c *gin.Context) {
+		key, limit, tooManyRequests := h.rateLimitKey(c, policy)
+
+		// Лимит передается в скрипт на каждый запрос, поэтому смена тарифа действует сразу
+		result, err := slidingWindowScript.Run(c.Request.Context(), h.redis,
+			[]string{"rate_limit:" + name + ":" + key},
+			policy.Window.Milliseconds(), limit, uuid.NewString(),
+		).Int64Slice()
+		if err != nil || len(result) != 3 {
+			logger.FromContext(c.Request.Context()).Errorf("rate limiter %q failed: %v", name, err)
+			c.Next() // Если Redis упал, не блокируем пользователя
+			return
+		}
+
+		allowed, count, resetMs := result[0] == 1, result[1], result[2]
+		reset := (resetMs + 999) / 1000
+
+		c.Header("RateLimit-Policy", fmt.Sprintf("%d;w=%d", limit, int(policy.Window.Seconds())))
+		c.Header("RateLimit-Limit", strconv.Itoa(limit))
+		c.Header("RateLimit-Remaining", strconv.FormatInt(max(int64(limit)-count, 0), 10))
+		c.Header("RateLimit-Reset", strconv.FormatInt(reset, 10))
+
+		if !allowed {
+			metrics.RateLimitRejections.WithLabelValues(name).Inc()
+			c.Header("Retry-After", strconv.FormatInt(reset, 10))
+			handleError(c, tooManyRequests)
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// rateLimitKey выбирает, по чему считать запросы и какой лимит действует.
+// Запросы пользователя считаются по id: новый access токен не дает новый бюджет.
+func (h *Handler) rateLimitKey(c *gin.Context, policy RateLimitPolicy) (string, int, error) {
+	if policy.Key == rateLimitKeyUser {
+		if userId, err := getUserID(c); err == nil {
+			return "user:" + strconv.Itoa(userId), h.tierLimit(c.Request.Context(), userId, policy), domain.ErrTooManyRequestsByUser
+		}
+	}
+	return "ip:" + c.ClientIP(), policy.Limit, domain.ErrTooManyRequestsByIp
+}
+
+// tierLimit — лимит тарифа пользователя. Если тариф узнать не удалось, действует базовый лимит.
+func (h *Handler) tierLimit(ctx context.Context, userId int, policy RateLimitPolicy) int {
+	if len(policy.Tiers) == 0 {
+		return policy.Limit
+	}
+
+	tier, err := h.services.UserSettingsService.GetTier(ctx, userId)
+	if err != nil {
+		logger.FromContext(ctx).Errorf("failed to get tier of user %d: %v", userId, err)
+		return policy.Limit
+	}
+
+	if limit, ok := policy.Tiers[tier]; ok && limit > 0 {
+		return limit
+	}
+	return policy.Limit
+}
@@ -0,0 +1,81 @@
+package rest
+
+import (
+	"regexp"
+	"time"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/logger"
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"github.com/sirupsen/logrus"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const requestIDHeader = "X-Request-ID"
+
+// requestIDPattern — какой id запроса от клиента или прокси принимаем как есть.
+// Остальные заменяем своим, чтобы в лог не попали переводы строк и мусор.
+var requestIDPattern = regexp.MustCompile(`^[A-Za-z0-9._\-]{1,128}$`)
+
+// requestContext назначает запросу X-Request-ID, кладет в context.Context логгер
+// с request_id, методом и маршрутом (его получают сервисы) и после обработки
+// пишет access лог. Токены, пароли и адреса почты из строки запроса скрываются.
+func requestContext(c *gin.Context) {
+	start := time.Now()
+
+	requestID := c.GetHeader(requestIDHeader)
+	if !requestIDPattern.MatchString(requestID) {
+		requestID = uuid.NewString()
+	}
+	c.Header(requestIDHeader, requestID)
+
+	route := c.FullPath()
+	if route == "" {
+		route = "not_found"
+	}
+	fields := logrus.Fields{
+		logger.FieldRequestID: requestID,
+		logger.FieldMethod:    c.Request.Method,
+		logger.FieldRoute:     route,
+	}
+	// По trace_id запись лога находится в трассировке и наоборот
+	if spanContext := trace.SpanContextFromContext(c.Request.Context()); spanContext.IsValid() {
+		fields[logger.FieldTraceID] = spanContext.TraceID().String()
+		trace.SpanFromContext(c.Request.Context()).SetAttributes(attribute.String("request.id", requestID))
+	}
+	ctx := logger.WithFields(c.Request.Context(), fields)
+	c.Request = c.Request.WithContext(ctx)
+
+	c.Next()
+
+	status := c.Writer.Status()
+	if probeRoutes[route] && status < 500 {
+		return
+	}
+	entry := logger.FromContext(c.Request.Context()).WithFields(logrus.Fields{
+		"status":     status,
+		"latency_ms": time.Since(start).Milliseconds(),
+		"path":       c.Request.URL.Path,
+		"query":      logger.RedactQuery(c.Request.URL.RawQuery),
+		"ip":         c.ClientIP(),
+		"user_agent": c.Request.UserAgent(),
+		"bytes":      c.Writer.Size(),
+	})
+
+	switch {
+	case status >= 500:
+		entry.Error("request completed")
+	case status >= 400:
+		entry.Warn("request completed")
+	default:
+		entry.Info("request completed")
+	}
+}
+
+// setRequestUser добавляет id пользователя к логгеру запроса после проверки токена
+func setRequestUser(c *gin.Context, userId int) {
+	ctx := logger.WithFields(c.Request.Context(), logrus.Fields{logger.FieldUserID: userId})
+	c.Request = c.Request.WithContext(ctx)
+	trace.SpanFromContext(ctx).SetAttributes(attribute.Int("enduser.id", userId))
+}
@@ -6,8 +6,8 @@ import (
 	"net/http"
 
 	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/ArtemChadaev/SeeThisGame/internal/logger"
 	"github.com/gin-gonic/gin"
-	"github.com/sirupsen/logrus"
 )
 
 type ErrorResponse struct {
@@ -23,14 +23,14 @@ func handleError(c *gin.Context, err error) {
 		if appErr.Err != nil {
 			logMessage = fmt.Sprintf("%s: %v", appErr.Message, appErr.Err)
 		}
-		logrus.Error(logMessage)
+		logger.FromContext(c.Request.Context()).Error(logger.Redact(logMessage))
 
 		c.AbortWithStatusJSON(appErr.HTTPStatus, ErrorResponse{
 			ErrorField:       appErr.Code,
 			ErrorDescription: appErr.Message,
 		})
 	} else {
-		logrus.Errorf("unexpected error: %v", err)
+		logger.FromContext(c.Request.Context()).Errorf("unexpected error: %s", logger.Redact(err.Error()))
 		c.AbortWithStatusJSON(http.StatusInternalServerError, ErrorResponse{
 			ErrorField:       "internal_server_error",
 			ErrorDescription: "An internal server error occurred.",
@@ -0,0 +1,77 @@
+package rest
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/gin-gonic/gin"
+)
+
+func (h *Handler) getRoles(c *gin.Context) {
+	roles, err := h.services.RoleService.GetRoles(c.Request.Context())
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, roles)
+}
+
+func (h *Handler) getUserRoles(c *gin.Context) {
+	userId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handleError(c, domain.ErrUserNotFound)
+		return
+	}
+
+	roles, err := h.services.RoleService.GetUserRoles(c.Request.Context(), userId)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"roles": roles})
+}
+
+func (h *Handler) grantRole(c *gin.Context) {
+	actorId, err := getUserID(c)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	userId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handleError(c, domain.ErrUserNotFound)
+		return
+	}
+
+	if err := h.services.RoleService.GrantRole(c.Request.Context(), actorId, userId, c.Param("role")); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Роль выдана"})
+}
+
+func (h *Handler) revokeRole(c *gin.Context) {
+	actorId, err := getUserID(c)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	userId, err := strconv.Atoi(c.Param("id"))
+	if err != nil {
+		handleError(c, domain.ErrUserNotFound)
+		return
+	}
+
+	if err := h.services.RoleService.RevokeRole(c.Request.Context(), actorId, userId, c.Param("role")); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Роль снята"})
+}
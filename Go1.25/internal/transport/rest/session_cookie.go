@@ -0,0 +1,127 @@
+package rest
+
+import (
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/ArtemChadaev/SeeThisGame/internal/service"
+	"github.com/gin-gonic/gin"
+)
+
+// Режим cookie для браузерного клиента (Nuxt). Клиент включает его заголовком
+// X-Session-Mode: cookie при входе. Refresh токен тогда живет в HttpOnly cookie,
+// которую браузер отправляет только на /auth/refresh, а в JSON приходит лишь access токен.
+// /auth/refresh с cookie защищен double-submit CSRF токеном: значение cookie csrf_token
+// должно совпасть с заголовком X-CSRF-Token. Боты и мобильные клиенты работают как раньше.
+const (
+	sessionModeHeader = "X-Session-Mode"
+	sessionModeCookie = "cookie"
+
+	refreshTokenCookie = "refresh_token"
+	refreshTokenPath   = "/auth/refresh"
+
+	csrfTokenCookie = "csrf_token"
+	// csrfTokenPath — весь сайт: SPA читает cookie через document.cookie на любой странице,
+	// в том числе после перезагрузки
+	csrfTokenPath   = "/"
+	csrfTokenHeader = "X-CSRF-Token"
+	// legacyCSRFTokenPath — прежний путь cookie; такая cookie удаляется, чтобы не перекрывать новую
+	legacyCSRFTokenPath = "/auth"
+)
+
+// SessionCookieConfig — настройки cookie сессии
+type SessionCookieConfig struct {
+	// Domain — домен cookie; пусто — только домен API
+	Domain string `mapstructure:"domain"`
+	// SameSite — strict (по умолчанию), lax или none (фронтенд на другом сайте, только с Secure)
+	SameSite string `mapstructure:"sameSite"`
+	// Insecure убирает флаг Secure. Только для локальной разработки по http.
+	Insecure bool `mapstructure:"insecure"`
+}
+
+func (cfg SessionCookieConfig) sameSite() http.SameSite {
+	switch strings.ToLower(cfg.SameSite) {
+	case "lax":
+		return http.SameSiteLaxMode
+	case "none":
+		return http.SameSiteNoneMode
+	default:
+		return http.SameSiteStrictMode
+	}
+}
+
+func cookieSessionRequested(c *gin.Context) bool {
+	return strings.EqualFold(c.GetHeader(sessionModeHeader), sessionModeCookie)
+}
+
+// writeTokens отвечает выданными токенами в режиме, который выбрал клиент
+func (h *Handler) writeTokens(c *gin.Context, tokens domain.ResponseTokens) {
+	if !cookieSessionRequested(c) {
+		c.JSON(http.StatusOK, tokens)
+		return
+	}
+
+	csrfToken, err := newCSRFToken()
+	if err != nil {
+		handleError(c, domain.NewInternalServerError(err))
+		return
+	}
+
+	maxAge := int(service.RefreshTokenTTL.Seconds())
+	h.setSessionCookie(c, refreshTokenCookie, tokens.RefreshToken, refreshTokenPath, maxAge, true)
+	// CSRF токен не HttpOnly: клиент на том же сайте читает его из document.cookie
+	// (cookie на пути /, поэтому видна с любой страницы), а для фронтенда на другом
+	// домене он дублируется в ответе
+	h.setSessionCookie(c, csrfTokenCookie, csrfToken, csrfTokenPath, maxAge, false)
+	h.setSessionCookie(c, csrfTokenCookie, "", legacyCSRFTokenPath, -1, false)
+
+	c.Header("Cache-Control", "no-store")
+	c.JSON(http.StatusOK, gin.H{
+		"accessToken": tokens.AccessToken,
+		"csrfToken":   csrfToken,
+	})
+}
+
+// cookieRefreshToken достает refresh токен из cookie и проверяет CSRF токен.
+// Пустая строка без ошибки — cookie нет, клиент работает в режиме JSON.
+func cookieRefreshToken(c *gin.Context) (string, error) {
+	refreshToken, err := c.Cookie(refreshTokenCookie)
+	if err != nil || refreshToken == "" {
+		return "", nil
+	}
+
+	csrfCookie, _ := c.Cookie(csrfTokenCookie)
+	csrfHeader := c.GetHeader(csrfTokenHeader)
+	if csrfCookie == "" || subtle.ConstantTimeCompare([]byte(csrfCookie), []byte(csrfHeader)) != 1 {
+		return "", domain.ErrInvalidCSRFToken
+	}
+
+	return refreshToken, nil
+}
+
+// clearSessionCookies удаляет cookie сессии (выход или недействительный refresh токен)
+func (h *Handler) clearSessionCookies(c *gin.Context) {
+	if _, err := c.Cookie(csrfTokenCookie); err != nil {
+		return
+	}
+	h.setSessionCookie(c, refreshTokenCookie, "", refreshTokenPath, -1, true)
+	h.setSessionCookie(c, csrfTokenCookie, "", csrfTokenPath, -1, false)
+	h.setSessionCookie(c, csrfTokenCookie, "", legacyCSRFTokenPath, -1, false)
+}
+
+func (h *Handler) setSessionCookie(c *gin.Context, name, value, path string, maxAge int, httpOnly bool) {
+	c.SetSameSite(h.cookies.sameSite())
+	c.SetCookie(name, value, maxAge, path, h.cookies.Domain, !h.cookies.Insecure, httpOnly)
+}
+
+func newCSRFToken() (string, error) {
+	tokenBytes := make([]byte, 32)
+	if _, err := rand.Read(tokenBytes); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(tokenBytes), nil
+}
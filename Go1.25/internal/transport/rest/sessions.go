@@ -0,0 +1,70 @@
+package rest
+
+import (
+	"net/http"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/gin-gonic/gin"
+)
+
+// deviceInfo собирает данные клиента для записи в сессию
+func deviceInfo(c *gin.Context) domain.DeviceInfo {
+	return domain.DeviceInfo{
+		UserAgent: c.Request.UserAgent(),
+		IP:        c.ClientIP(),
+	}
+}
+
+func (h *Handler) getSessions(c *gin.Context) {
+	userId, err := getUserID(c)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	sessions, err := h.services.AuthorizationService.GetSessions(c.Request.Context(), userId)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, sessions)
+}
+
+func (h *Handler) renameSession(c *gin.Context) {
+	userId, err := getUserID(c)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	var input struct {
+		NameDevice string `json:"nameDevice" binding:"required,max=255"`
+	}
+	if err := c.BindJSON(&input); err != nil {
+		handleError(c, domain.NewInvalidRequestError(err))
+		return
+	}
+
+	if err := h.services.AuthorizationService.RenameSession(c.Request.Context(), userId, c.Param("id"), input.NameDevice); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Устройство переименовано"})
+}
+
+func (h *Handler) deleteSession(c *gin.Context) {
+	userId, err := getUserID(c)
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	if err := h.services.AuthorizationService.DeleteSession(c.Request.Context(), userId, c.Param("id")); err != nil {
+		handleError(c, err)
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "Сессия завершена"})
+}
@@ -0,0 +1,50 @@
+package rest
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/ArtemChadaev/SeeThisGame/internal/domain"
+	"github.com/gin-gonic/gin"
+)
+
+// telegramSignIn принимает объект user из callback-а Telegram Login Widget (data-onauth).
+// Подписаны все поля объекта, поэтому принимаем их как есть, без фиксированной структуры.
+func (h *Handler) telegramSignIn(c *gin.Context) {
+	var payload map[string]any
+	decoder := json.NewDecoder(c.Request.Body)
+	// id и auth_date должны попасть в строку проверки ровно в том виде, в котором их подписал Telegram
+	decoder.UseNumber()
+	if err := decoder.Decode(&payload); err != nil {
+		handleError(c, domain.NewInvalidRequestError(err))
+		return
+	}
+
+	data := make(map[string]string, len(payload))
+	for key, value := range payload {
+		data[key] = fmt.Sprint(value)
+	}
+
+	h.finishTelegramSignIn(c, data)
+}
+
+// telegramRedirect — вариант виджета с data-auth-url: Telegram возвращает пользователя с данными в query
+func (h *Handler) telegramRedirect(c *gin.Context) {
+	query := c.Request.URL.Query()
+	data := make(map[string]string, len(query))
+	for key := range query {
+		data[key] = query.Get(key)
+	}
+
+	h.finishTelegramSignIn(c, data)
+}
+
+func (h *Handler) finishTelegramSignIn(c *gin.Context, data map[string]string) {
+	result, err := h.services.OAuthService.HandleTelegram(c.Request.Context(), data, deviceInfo(c))
+	if err != nil {
+		handleError(c, err)
+		return
+	}
+
+	h.writeSignInResult(c, result)
+}
@@ -31,7 +31,7 @@ func (h *Handler) getMySettings(c *gin.Context) {
 		return
 	}
 
-	settings, err := h.services.UserSettingsService.GetByUserID(userId)
+	settings, err := h.services.UserSettingsService.GetByUserID(c.Request.Context(), userId)
 	if err != nil {
 		handleError(c, err)
 		return
@@ -70,7 +70,7 @@ func (h *Handler) setNameIcon(c *gin.Context) {
 		return
 	}
 
-	if err := h.services.UserSettingsService.UpdateInfo(userId, newName, iconUrl); err != nil {
+	if err := h.services.UserSettingsService.UpdateInfo(c.Request.Context(), userId, newName, iconUrl); err != nil {
 		handleError(c, err)
 		return
 	}
@@ -89,7 +89,7 @@ func (h *Handler) dayCoin(c *gin.Context) {
 		return
 	}
 
-	if err = h.services.UserSettingsService.GetGrantDailyReward(userId); err != nil {
+	if err = h.services.UserSettingsService.GetGrantDailyReward(c.Request.Context(), userId); err != nil {
 		handleError(c, err)
 		return
 	}
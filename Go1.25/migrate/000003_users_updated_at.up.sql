@@ -0,0 +1,4 @@
+-- Триггер update_users_updated_at из 000001 пишет в NEW.updated_at,
+-- но колонки в users не было, из-за чего любой UPDATE users падал.
+ALTER TABLE users
+    ADD COLUMN updated_at TIMESTAMPTZ DEFAULT NOW();
@@ -0,0 +1,7 @@
+DROP TABLE IF EXISTS security_events;
+
+DROP INDEX IF EXISTS idx_user_refresh_tokens_family_id;
+
+ALTER TABLE user_refresh_tokens
+    DROP COLUMN IF EXISTS rotated_at,
+    DROP COLUMN IF EXISTS family_id;
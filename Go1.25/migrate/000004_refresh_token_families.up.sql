@@ -0,0 +1,19 @@
+-- Семейства refresh токенов: при каждой ротации создается новая строка с тем же family_id,
+-- а старая помечается rotated_at. Повторное предъявление старого токена = признак кражи.
+ALTER TABLE user_refresh_tokens
+    ADD COLUMN family_id  UUID NOT NULL DEFAULT gen_random_uuid(),
+    ADD COLUMN rotated_at TIMESTAMPTZ;
+
+CREATE INDEX idx_user_refresh_tokens_family_id ON user_refresh_tokens (family_id);
+
+-- Журнал событий безопасности (повторное использование токена и т.д.)
+CREATE TABLE security_events
+(
+    id         SERIAL PRIMARY KEY,
+    user_id    INT REFERENCES users (id) ON DELETE CASCADE,
+    event_type VARCHAR(64) NOT NULL,
+    details    JSONB       NOT NULL DEFAULT '{}',
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_security_events_user_id ON security_events (user_id);
@@ -0,0 +1,3 @@
+ALTER TABLE user_refresh_tokens
+    DROP COLUMN IF EXISTS last_used_at,
+    DROP COLUMN IF EXISTS ip_address;
@@ -0,0 +1,4 @@
+-- Данные об устройстве для управления сессиями (/api/sessions)
+ALTER TABLE user_refresh_tokens
+    ADD COLUMN ip_address   VARCHAR(64),
+    ADD COLUMN last_used_at TIMESTAMPTZ NOT NULL DEFAULT NOW();
@@ -0,0 +1,4 @@
+-- Подтверждение email
+ALTER TABLE users
+    ADD COLUMN email_verified    BOOLEAN NOT NULL DEFAULT FALSE,
+    ADD COLUMN email_verified_at TIMESTAMPTZ;
@@ -0,0 +1,23 @@
+-- TOTP (RFC 6238). Секрет активен только после подтверждения кодом (enabled).
+CREATE TABLE user_totp
+(
+    user_id        INT PRIMARY KEY REFERENCES users (id) ON DELETE CASCADE,
+    secret         VARCHAR(64) NOT NULL,
+    enabled        BOOLEAN     NOT NULL DEFAULT FALSE,
+    -- Последний принятый временной шаг: один код нельзя использовать дважды
+    last_used_step BIGINT      NOT NULL DEFAULT 0,
+    created_at     TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    confirmed_at   TIMESTAMPTZ
+);
+
+-- Одноразовые коды восстановления, хранятся только хеши
+CREATE TABLE user_recovery_codes
+(
+    id         SERIAL PRIMARY KEY,
+    user_id    INT         NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    code_hash  VARCHAR(64) NOT NULL,
+    used_at    TIMESTAMPTZ,
+    created_at TIMESTAMPTZ NOT NULL DEFAULT NOW()
+);
+
+CREATE INDEX idx_user_recovery_codes_user_id ON user_recovery_codes (user_id);
@@ -0,0 +1,14 @@
+-- Ключи доступа WebAuthn (passkeys). credential — запись библиотеки целиком в JSON,
+-- credential_id вынесен отдельно для уникальности и поиска.
+CREATE TABLE user_passkeys
+(
+    id            SERIAL PRIMARY KEY,
+    user_id       INT         NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    credential_id BYTEA       NOT NULL UNIQUE,
+    credential    JSONB       NOT NULL,
+    name          VARCHAR(255),
+    created_at    TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    last_used_at  TIMESTAMPTZ
+);
+
+CREATE INDEX idx_user_passkeys_user_id ON user_passkeys (user_id);
@@ -0,0 +1,26 @@
+-- Пустая строка допустима только у одного пользователя, поэтому аккаунтам без email
+-- выдаем заглушку
+UPDATE users
+SET email = 'user-' || id || '@users.invalid'
+WHERE email IS NULL;
+ALTER TABLE users
+    ALTER COLUMN email SET NOT NULL;
+
+ALTER TABLE users
+    ADD COLUMN oauth_provider VARCHAR(50),
+    ADD COLUMN oauth_id VARCHAR(255);
+
+-- В старой схеме у пользователя только один провайдер — берем самый ранний
+UPDATE users u
+SET oauth_provider = i.provider,
+    oauth_id       = i.provider_user_id
+FROM (SELECT DISTINCT ON (user_id) user_id, provider, provider_user_id
+      FROM user_identities
+      ORDER BY user_id, id) i
+WHERE u.id = i.user_id;
+
+CREATE UNIQUE INDEX idx_users_oauth_provider_id
+    ON users (oauth_provider, oauth_id)
+    WHERE oauth_provider IS NOT NULL AND oauth_id IS NOT NULL;
+
+DROP TABLE IF EXISTS user_identities;
@@ -0,0 +1,33 @@
+-- Внешние способы входа (Google, GitHub и будущие провайдеры). У одного аккаунта
+-- их может быть несколько; пароль по-прежнему хранится в users.password_hash.
+CREATE TABLE user_identities
+(
+    id               SERIAL PRIMARY KEY,
+    user_id          INT          NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    provider         VARCHAR(50)  NOT NULL,
+    provider_user_id VARCHAR(255) NOT NULL,
+    email            VARCHAR(255),
+    created_at       TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    last_used_at     TIMESTAMPTZ,
+    UNIQUE (provider, provider_user_id),
+    UNIQUE (user_id, provider)
+);
+
+INSERT INTO user_identities (user_id, provider, provider_user_id, email)
+SELECT id, oauth_provider, oauth_id, NULLIF(email, '')
+FROM users
+WHERE oauth_provider IS NOT NULL
+  AND oauth_id IS NOT NULL;
+
+DROP INDEX IF EXISTS idx_users_oauth_provider_id;
+ALTER TABLE users
+    DROP COLUMN oauth_provider,
+    DROP COLUMN oauth_id;
+
+-- Аккаунт может быть без email (например, скрытая почта на GitHub). Храним NULL,
+-- а не пустую строку, иначе второй такой пользователь упирается в UNIQUE.
+ALTER TABLE users
+    ALTER COLUMN email DROP NOT NULL;
+UPDATE users
+SET email = NULL
+WHERE email = '';
@@ -0,0 +1,45 @@
+-- Роли и права. Обычный игрок ролей не имеет; роли выдаются персоналу.
+-- Первого администратора назначают вручную:
+--   INSERT INTO user_roles (user_id, role_id) SELECT <id>, id FROM roles WHERE name = 'admin';
+CREATE TABLE roles
+(
+    id          SERIAL PRIMARY KEY,
+    name        VARCHAR(50) UNIQUE NOT NULL,
+    description VARCHAR(255)
+);
+
+CREATE TABLE permissions
+(
+    name        VARCHAR(100) PRIMARY KEY,
+    description VARCHAR(255)
+);
+
+CREATE TABLE role_permissions
+(
+    role_id    INT          NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    permission VARCHAR(100) NOT NULL REFERENCES permissions (name) ON DELETE CASCADE,
+    PRIMARY KEY (role_id, permission)
+);
+
+CREATE TABLE user_roles
+(
+    user_id    INT         NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    role_id    INT         NOT NULL REFERENCES roles (id) ON DELETE CASCADE,
+    granted_by INT REFERENCES users (id) ON DELETE SET NULL,
+    granted_at TIMESTAMPTZ NOT NULL DEFAULT NOW(),
+    PRIMARY KEY (user_id, role_id)
+);
+
+INSERT INTO roles (name, description)
+VALUES ('admin', 'Полный доступ к администрированию'),
+       ('moderator', 'Модерация игроков и контента');
+
+INSERT INTO permissions (name, description)
+VALUES ('roles:read', 'Просмотр ролей и ролей пользователей'),
+       ('roles:manage', 'Выдача и снятие ролей');
+
+INSERT INTO role_permissions (role_id, permission)
+SELECT r.id, p.name
+FROM roles r
+         JOIN permissions p ON r.name = 'admin'
+    OR (r.name = 'moderator' AND p.name = 'roles:read');
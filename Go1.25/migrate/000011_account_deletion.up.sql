@@ -0,0 +1,7 @@
+-- Удаление аккаунта по запросу пользователя. До этого момента вход отменяет удаление,
+-- после него строка удаляется, а данные в остальных таблицах — через ON DELETE CASCADE.
+ALTER TABLE users
+    ADD COLUMN deletion_scheduled_at TIMESTAMPTZ;
+
+CREATE INDEX idx_users_deletion_scheduled_at ON users (deletion_scheduled_at)
+    WHERE deletion_scheduled_at IS NOT NULL;
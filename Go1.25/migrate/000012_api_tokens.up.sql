@@ -0,0 +1,16 @@
+-- Персональные токены доступа к API для ботов и скриптов. Хранится только SHA-256 токена;
+-- prefix — начало токена, по которому пользователь узнает его в списке.
+CREATE TABLE api_tokens
+(
+    id           SERIAL PRIMARY KEY,
+    user_id      INT          NOT NULL REFERENCES users (id) ON DELETE CASCADE,
+    name         VARCHAR(100) NOT NULL,
+    token_hash   VARCHAR(64)  NOT NULL UNIQUE,
+    prefix       VARCHAR(16)  NOT NULL,
+    scopes       TEXT[]       NOT NULL DEFAULT '{}',
+    created_at   TIMESTAMPTZ  NOT NULL DEFAULT NOW(),
+    expires_at   TIMESTAMPTZ,
+    last_used_at TIMESTAMPTZ
+);
+
+CREATE INDEX idx_api_tokens_user_id ON api_tokens (user_id);
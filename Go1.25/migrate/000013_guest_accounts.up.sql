@@ -0,0 +1,6 @@
+-- Гостевые аккаунты: без email, пароля и провайдеров, вход только по refresh токену.
+-- Гость становится обычным аккаунтом, когда привязывает email/пароль или провайдера.
+ALTER TABLE users
+    ADD COLUMN is_guest BOOLEAN NOT NULL DEFAULT FALSE;
+
+CREATE INDEX idx_users_is_guest ON users (id) WHERE is_guest;
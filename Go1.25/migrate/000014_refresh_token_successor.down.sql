@@ -0,0 +1,4 @@
+DROP INDEX IF EXISTS idx_user_refresh_tokens_rotated_at;
+
+ALTER TABLE user_refresh_tokens
+    DROP COLUMN IF EXISTS replaced_by;
@@ -0,0 +1,7 @@
+-- Ссылка ротированного токена на выданный вместо него: два параллельных обмена
+-- одного токена (две вкладки) получают один и тот же новый токен, а не отзыв семейства
+ALTER TABLE user_refresh_tokens
+    ADD COLUMN replaced_by INT REFERENCES user_refresh_tokens (id) ON DELETE SET NULL;
+
+-- Для фоновой очистки ротированных токенов
+CREATE INDEX idx_user_refresh_tokens_rotated_at ON user_refresh_tokens (rotated_at) WHERE rotated_at IS NOT NULL;